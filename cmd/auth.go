@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod/pkg/log"
+	"golang.org/x/term"
+)
+
+// handleAuthError prints the reason of an AuthError instead of the api call that hit it and, if the
+// provider runs in a terminal with the gcloud application default credentials, starts a new login
+func handleAuthError(err error, log log.Logger) bool {
+	authErr := &gcloud.AuthError{}
+	if !errors.As(err, &authErr) {
+		return false
+	}
+
+	log.Debugf("%v", err)
+	log.Error(authErr.Error())
+	if reauthenticate(log) {
+		log.Infof("Logged in successfully, please run the command again")
+	}
+
+	return true
+}
+
+// reauthenticate runs gcloud auth application-default login and returns false if it didn't run
+func reauthenticate(log log.Logger) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || os.Getenv("GCLOUD_JSON_AUTH") != "" || os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" {
+		return false
+	}
+
+	gcloudPath, err := exec.LookPath("gcloud")
+	if err != nil {
+		return false
+	}
+
+	loginCmd := exec.Command(gcloudPath, "auth", "application-default", "login")
+	loginCmd.Stdin = os.Stdin
+	loginCmd.Stdout = os.Stderr
+	loginCmd.Stderr = os.Stderr
+	err = loginCmd.Run()
+	if err != nil {
+		log.Errorf("Error running gcloud auth application-default login: %v", err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// BackupCmd holds the cmd flags
+type BackupCmd struct {
+	Name            string
+	StorageLocation string
+}
+
+// NewBackupCmd defines a command
+func NewBackupCmd() *cobra.Command {
+	cmd := &BackupCmd{}
+	backupCmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Capture the instance with all its disks in a machine image",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(true)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
+		},
+	}
+
+	backupCmd.Flags().StringVar(&cmd.Name, "name", "", "The name of the machine image, defaults to the machine name with a timestamp suffix")
+	backupCmd.Flags().StringVar(&cmd.StorageLocation, "storage-location", "", "The region or multi-region to store the machine image in, e.g. eu")
+	return backupCmd
+}
+
+// Run runs the command logic
+func (cmd *BackupCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	instance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		return fmt.Errorf("instance %s doesn't exist", options.MachineID)
+	}
+
+	name := cmd.Name
+	if name == "" {
+		name = backupName(options.MachineID, time.Now())
+	}
+
+	log.Infof("Creating machine image %s of instance %s, this can take a few minutes", name, options.MachineID)
+	err = client.CreateMachineImage(ctx, name, options.MachineID, cmd.StorageLocation)
+	if err != nil {
+		return errors.Wrap(err, "create machine image")
+	}
+
+	log.Donef("Successfully backed up %s to projects/%s/global/machineImages/%s", options.MachineID, options.Project, name)
+	return nil
+}
+
+// backupName shortens the machine name so the timestamp suffix always fits into 63 characters
+func backupName(machineID string, t time.Time) string {
+	suffix := fmt.Sprintf("-backup-%d", t.Unix())
+	if len(machineID) > 63-len(suffix) {
+		machineID = strings.TrimRight(machineID[:63-len(suffix)], "-")
+	}
+
+	return machineID + suffix
+}
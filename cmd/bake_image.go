@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/startup"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// BakeImageCmd holds the cmd flags
+type BakeImageCmd struct {
+	Name    string
+	Timeout time.Duration
+}
+
+// NewBakeImageCmd defines a command
+func NewBakeImageCmd() *cobra.Command {
+	cmd := &BakeImageCmd{}
+	bakeImageCmd := &cobra.Command{
+		Use:   "bake-image",
+		Short: "Bake a provisioned image into the configured image family",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(false)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
+		},
+	}
+
+	bakeImageCmd.Flags().StringVar(&cmd.Name, "name", "", "The name of the image to create, defaults to the family name with a timestamp suffix")
+	bakeImageCmd.Flags().DurationVar(&cmd.Timeout, "timeout", 20*time.Minute, "How long to wait for the provisioning to finish")
+	return bakeImageCmd
+}
+
+// Run runs the command logic
+func (cmd *BakeImageCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	if options.ImageFamily == "" {
+		return fmt.Errorf("couldn't find option IMAGE_FAMILY in environment, please make sure IMAGE_FAMILY is defined")
+	}
+
+	imageName := cmd.Name
+	if imageName == "" {
+		imageName = fmt.Sprintf("%s-%d", options.ImageFamily, time.Now().Unix())
+	}
+
+	// the temporary instance is always built from the base image
+	machineFolder, err := os.MkdirTemp("", "devpod-bake-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(machineFolder)
+
+	bakeOptions := *options
+	bakeOptions.MachineID = fmt.Sprintf("devpod-bake-%d", time.Now().Unix())
+	bakeOptions.DevPodMachineID = bakeOptions.MachineID
+	bakeOptions.MachineFolder = machineFolder
+	bakeOptions.ImageFamily = ""
+	bakeOptions.DataDiskSize = 0
+	bakeOptions.LocalSSDCount = 0
+	bakeOptions.RelocateAgentDir = false
+
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	instance, err := buildInstance(&bakeOptions)
+	if err != nil {
+		return err
+	}
+	stampInstance(ctx, client, instance, "bake-image")
+
+	log.Infof("Creating temporary instance %s from %s", bakeOptions.MachineID, sourceImage(&bakeOptions))
+	err = createInstance(ctx, client, instance, &bakeOptions, log)
+	if err != nil {
+		return errors.Wrap(err, "create temporary instance")
+	}
+	defer func() {
+		log.Infof("Deleting temporary instance %s", bakeOptions.MachineID)
+		err := client.Delete(context.Background(), bakeOptions.MachineID)
+		if err != nil {
+			log.Errorf("Error deleting temporary instance %s: %v", bakeOptions.MachineID, err)
+		}
+	}()
+
+	log.Infof("Waiting for provisioning to finish")
+	err = waitForProvisioning(ctx, client, bakeOptions.MachineID, cmd.Timeout)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Stopping temporary instance")
+	err = client.Stop(ctx, bakeOptions.MachineID, bakeOptions.LocalSSDCount > 0, false)
+	if err != nil {
+		return errors.Wrap(err, "stop temporary instance")
+	}
+
+	created, err := client.Get(ctx, bakeOptions.MachineID)
+	if err != nil {
+		return err
+	} else if created == nil || len(created.Disks) == 0 || created.Disks[0].Source == nil {
+		return fmt.Errorf("couldn't find boot disk of instance %s", bakeOptions.MachineID)
+	}
+
+	log.Infof("Creating image %s in family %s", imageName, options.ImageFamily)
+	err = client.CreateImage(ctx, imageName, options.ImageFamily, *created.Disks[0].Source)
+	if err != nil {
+		return errors.Wrap(err, "create image")
+	}
+
+	log.Donef("Successfully baked image %s", gcloud.FamilyImage(options.Project, options.ImageFamily))
+	return nil
+}
+
+func waitForProvisioning(ctx context.Context, client *gcloud.Client, name string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		output, err := client.GetSerialPortOutput(ctx, name, 0)
+		if err == nil && output.Contents != nil && strings.Contains(*output.Contents, startup.DoneMarker) {
+			return nil
+		}
+
+		phase, err := client.GetGuestAttribute(ctx, name, startup.PhaseKey)
+		if err == nil && strings.HasPrefix(phase, startup.PhaseErrorPrefix) {
+			return fmt.Errorf("provisioning of instance %s failed: %s", name, strings.TrimPrefix(phase, startup.PhaseErrorPrefix))
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for instance %s to finish provisioning", name)
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
@@ -3,18 +3,23 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 
+	"cloud.google.com/go/compute/apiv1/computepb"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ssh"
 	"github.com/loft-sh/devpod/pkg/log"
-	"github.com/loft-sh/devpod/pkg/ssh"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 // CommandCmd holds the cmd flags
-type CommandCmd struct{}
+type CommandCmd struct {
+	Forwards []string
+}
 
 // NewCommandCmd defines a command
 func NewCommandCmd() *cobra.Command {
@@ -22,15 +27,16 @@ func NewCommandCmd() *cobra.Command {
 	commandCmd := &cobra.Command{
 		Use:   "command",
 		Short: "Run a command on the instance",
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
 			options, err := options.FromEnv(true)
 			if err != nil {
 				return err
 			}
 
-			return cmd.Run(context.Background(), options, log.Default)
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
 		},
 	}
+	commandCmd.Flags().StringArrayVar(&cmd.Forwards, "forward", nil, "Forward a port while the command runs, [L:|R:][bind_address:]port:host:hostport or D:[bind_address:]port")
 
 	return commandCmd
 }
@@ -41,41 +47,156 @@ func (cmd *CommandCmd) Run(ctx context.Context, options *options.Options, log lo
 	if command == "" {
 		return fmt.Errorf("command environment variable is missing")
 	}
-
-	// get private key
-	privateKey, err := ssh.GetPrivateKeyRawBase(options.MachineFolder)
+	forwards, err := parseForwards(cmd.Forwards)
 	if err != nil {
-		return fmt.Errorf("load private key: %w", err)
+		return err
 	}
 
 	// create gcloud client
-	client, err := gcloud.NewClient(ctx, options.Project, options.Zone)
+	client, err := gcloud.NewClientFromOptions(ctx, options)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
+	sshClient, err := newSSHClient(ctx, client, options)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	stopForwards, err := startForwards(sshClient, forwards, log)
+	if err != nil {
+		return err
+	}
+	defer stopForwards()
+
+	// run command
+	if options.SSHAgentForwarding {
+		err = ssh.ForwardAgent(sshClient)
+		if err != nil {
+			return err
+		}
+
+		return ssh.RunForwardAgent(ctx, sshClient, command, os.Stdin, os.Stdout, os.Stderr)
+	}
+
+	return ssh.Run(ctx, sshClient, command, os.Stdin, os.Stdout, os.Stderr)
+}
+
+// newSSHClient connects to the machine's instance
+func newSSHClient(ctx context.Context, client *gcloud.Client, options *options.Options) (*gossh.Client, error) {
+	// get private key
+	privateKey, err := loadPrivateKey(options)
+	if err != nil {
+		return nil, fmt.Errorf("load private key: %w", err)
+	}
+
+	return newSSHClientWithKey(ctx, client, options, privateKey)
+}
+
+// newSSHClientWithKey connects to the machine's instance with the given private key
+func newSSHClientWithKey(ctx context.Context, client *gcloud.Client, options *options.Options, privateKey []byte) (*gossh.Client, error) {
 	// get instance
 	instance, err := client.Get(ctx, options.MachineID)
 	if err != nil {
-		return err
+		return nil, err
 	} else if instance == nil {
-		return fmt.Errorf("instance %s doesn't exist", options.MachineID)
+		return nil, fmt.Errorf("instance %s doesn't exist", options.MachineID)
+	}
+
+	// get address
+	ip, err := instanceIP(instance, options)
+	if err != nil {
+		return nil, err
+	}
+
+	sshClient, err := ssh.NewSSHClient("devpod", net.JoinHostPort(ip, "22"), privateKey, options.SSHAgent, bastion(options))
+	if err != nil {
+		return nil, errors.Wrap(err, "create ssh client")
+	}
+
+	return sshClient, nil
+}
+
+// noPublicIPKey is the metadata key that records that the instance was created without a public ip
+const noPublicIPKey = "devpod-no-public-ip"
+
+// instanceIP returns the address to connect to, which is the internal ip when going through a bastion
+// or when the instance has no public ip
+func instanceIP(instance *computepb.Instance, options *options.Options) (string, error) {
+	if len(instance.NetworkInterfaces) == 0 {
+		return "", fmt.Errorf("instance %s doesn't have a network interface", instance.GetName())
+	}
+
+	if options.BastionHost != "" || options.NoPublicIP || metadataItem(instance.GetMetadata().GetItems(), noPublicIPKey) != nil {
+		if instance.NetworkInterfaces[0].NetworkIP == nil {
+			return "", fmt.Errorf("instance %s doesn't have an internal ip", instance.GetName())
+		}
+
+		return *instance.NetworkInterfaces[0].NetworkIP, nil
+	}
+
+	// prefer ipv6 if both sides support it
+	if ipv6 := instance.NetworkInterfaces[0].Ipv6AccessConfigs; len(ipv6) > 0 && ipv6[0].ExternalIpv6 != nil && hasIPv6Connectivity() {
+		return *ipv6[0].ExternalIpv6, nil
 	}
 
 	// get external ip
-	if len(instance.NetworkInterfaces) == 0 || len(instance.NetworkInterfaces[0].AccessConfigs) == 0 || instance.NetworkInterfaces[0].AccessConfigs[0].NatIP == nil {
-		return fmt.Errorf("instance %s doesn't have an external nat ip", options.MachineID)
+	if len(instance.NetworkInterfaces[0].AccessConfigs) == 0 || instance.NetworkInterfaces[0].AccessConfigs[0].NatIP == nil {
+		return "", fmt.Errorf("instance %s doesn't have an external nat ip", instance.GetName())
 	}
 
-	// get external address
-	externalIP := *instance.NetworkInterfaces[0].AccessConfigs[0].NatIP
-	sshClient, err := ssh.NewSSHClient("devpod", externalIP+":22", privateKey)
+	return *instance.NetworkInterfaces[0].AccessConfigs[0].NatIP, nil
+}
+
+// hasIPv6Connectivity checks if the client has a route to the ipv6 internet. Dialing udp
+// doesn't send any packets, it only resolves a route.
+func hasIPv6Connectivity() bool {
+	conn, err := net.Dial("udp6", "[2001:4860:4860::8888]:53")
 	if err != nil {
-		return errors.Wrap(err, "create ssh client")
+		return false
 	}
-	defer sshClient.Close()
+	_ = conn.Close()
 
-	// run command
-	return ssh.Run(ctx, sshClient, command, os.Stdin, os.Stdout, os.Stderr)
+	return true
+}
+
+func bastion(options *options.Options) *ssh.Bastion {
+	if options.BastionHost == "" {
+		return nil
+	}
+
+	return &ssh.Bastion{
+		Host: options.BastionHost,
+		User: options.BastionUser,
+	}
+}
+
+func parseForwards(specs []string) ([]ssh.Forward, error) {
+	forwards := []ssh.Forward{}
+	for _, spec := range specs {
+		forward, err := ssh.ParseForward(spec)
+		if err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, forward)
+	}
+
+	return forwards, nil
+}
+
+// startForwards starts the port forwardings through the ssh client and returns a function that stops them.
+// It only logs to stderr, stdout belongs to the ssh session.
+func startForwards(sshClient *gossh.Client, forwards []ssh.Forward, log log.Logger) (func(), error) {
+	log = log.ErrorStreamOnly()
+	stop, err := ssh.StartForwards(sshClient, forwards, log.Warnf)
+	if err != nil {
+		return nil, err
+	}
+	for _, forward := range forwards {
+		log.Infof("Forwarding %s", forward)
+	}
+
+	return stop, nil
 }
@@ -3,18 +3,18 @@ package cmd
 import (
 	"cloud.google.com/go/compute/apiv1/computepb"
 	"context"
-	"encoding/base64"
 	"fmt"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/startup"
 	"github.com/loft-sh/devpod/pkg/log"
-	"github.com/loft-sh/devpod/pkg/ssh"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"regexp"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // CreateCmd holds the cmd flags
@@ -26,13 +26,13 @@ func NewCreateCmd() *cobra.Command {
 	createCmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create an instance",
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
 			options, err := options.FromEnv(true)
 			if err != nil {
 				return err
 			}
 
-			return cmd.Run(context.Background(), options, log.Default)
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
 		},
 	}
 
@@ -40,19 +40,187 @@ func NewCreateCmd() *cobra.Command {
 }
 
 // Run runs the command logic
-func (cmd *CreateCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
-	client, err := gcloud.NewClient(ctx, options.Project, options.Zone)
+func (cmd *CreateCmd) Run(ctx context.Context, options *options.Options, log log.Logger) (err error) {
+	defer observeInstanceReady("create", options, time.Now(), &err)
+
+	client, err := gcloud.NewClientFromOptions(ctx, options)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
+	err = checkProjectPermissions(ctx, client, log)
+	if err != nil {
+		return err
+	}
+
+	// recorded before anything is created, so an interrupted create is cleaned up in the same project
+	err = options.RecordProject()
+	if err != nil {
+		return errors.Wrap(err, "record project")
+	}
+
+	// the name is only rendered once, so changing NAME_TEMPLATE doesn't lose the machine
+	err = options.RecordName()
+	if err != nil {
+		return errors.Wrap(err, "record name")
+	}
+
+	err = checkOrgPolicies(ctx, client, options, log)
+	if err != nil {
+		return err
+	}
+
 	instance, err := buildInstance(options)
 	if err != nil {
 		return err
 	}
 
-	return client.Create(ctx, instance)
+	// an earlier create might have been interrupted after the instance was created
+	existing, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	} else if existing != nil {
+		log.Infof("Instance %s already exists, reconciling it", options.MachineID)
+		err = reconcileInstance(ctx, client, existing, instance, log)
+		if err != nil {
+			return err
+		}
+		err = reconcileMachineType(ctx, client, existing, instance, log)
+		if err != nil {
+			return err
+		}
+
+		if existing.GetStatus() == "TERMINATED" || existing.GetStatus() == "SUSPENDED" {
+			err = client.Start(ctx, options.MachineID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return waitUntilReady(ctx, client, options, log)
+	}
+
+	// reattach the boot disk a deleted machine with the same id left behind
+	if options.KeepDiskOnDelete {
+		disk, err := client.GetDisk(ctx, options.MachineID)
+		if err != nil {
+			return err
+		} else if disk != nil {
+			if len(disk.GetUsers()) > 0 {
+				return fmt.Errorf("retained boot disk %s is still attached to %s", disk.GetName(), path.Base(disk.GetUsers()[0]))
+			}
+
+			log.Infof("Reattaching retained boot disk %s", disk.GetName())
+			options = fromRetainedDisk(options, disk)
+			instance, err = buildInstance(options)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	stampInstance(ctx, client, instance, "create")
+	if usesWarmPool(options) {
+		// the pool is only refilled once the machine is usable
+		defer func() {
+			if err == nil {
+				replenishPool(log)
+			}
+		}()
+
+		claimed, err := claimPoolInstance(ctx, client, options, instance, log)
+		if err != nil {
+			return err
+		} else if claimed {
+			return waitUntilReady(ctx, client, options, log)
+		}
+	}
+
+	err = checkAvailability(ctx, client, options)
+	if err != nil {
+		return err
+	}
+
+	err = createInstance(ctx, client, instance, options, log)
+	if err != nil {
+		return err
+	}
+
+	err = waitUntilReady(ctx, client, options, log)
+	if err != nil && ctx.Err() != nil {
+		// aborted before the instance became usable
+		rollbackInstance(client, options.MachineID, "", log)
+	}
+
+	return err
+}
+
+// checkAvailability makes sure the requested machine shape exists in the zone before creating the instance
+func checkAvailability(ctx context.Context, client *gcloud.Client, options *options.Options) error {
+	err := client.CheckMachineTypeAvailability(ctx, options.MachineType)
+	if err != nil {
+		return err
+	}
+
+	if options.AcceleratorType != "" {
+		err = client.CheckAcceleratorAvailability(ctx, options.AcceleratorType, options.AcceleratorCount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createInstance creates the instance, creating a boot disk with provisioned throughput upfront if needed.
+// If the user aborts, the resources created so far are deleted again.
+func createInstance(ctx context.Context, client *gcloud.Client, instance *computepb.Instance, options *options.Options, log log.Logger) error {
+	err := ensurePlacementPolicy(ctx, client, options)
+	if err != nil {
+		return err
+	}
+
+	if gpuNICs := options.GPUNetworkInterfaces(); gpuNICs > 0 && options.AdditionalNetworkInterfaces == "" {
+		log.Warnf("Machine type %s uses %d gpu nics for GPUDirect, add them with ADDITIONAL_NETWORK_INTERFACES for full gpu to gpu bandwidth between machines", options.MachineType, gpuNICs)
+	}
+
+	err = attachRetainedDataDisk(ctx, client, instance, log)
+	if err != nil {
+		return err
+	}
+
+	createdDisk := ""
+	bootDisk := instance.Disks[0]
+	if options.ProvisionedThroughput > 0 && bootDisk.InitializeParams != nil {
+		// reuse the disk of an interrupted create
+		disk, err := client.GetDisk(ctx, instance.GetName())
+		if err != nil {
+			return err
+		}
+
+		source := fmt.Sprintf("projects/%s/zones/%s/disks/%s", options.Project, options.Zone, instance.GetName())
+		if disk == nil {
+			source, err = client.CreateDiskWithThroughput(ctx, instance.GetName(), bootDisk.InitializeParams, int64(options.ProvisionedThroughput))
+			if err != nil {
+				if ctx.Err() != nil {
+					rollbackInstance(client, instance.GetName(), instance.GetName(), log)
+				}
+				return err
+			}
+			createdDisk = instance.GetName()
+		}
+
+		bootDisk.InitializeParams = nil
+		bootDisk.Source = ptr.Ptr(source)
+	}
+
+	err = client.Create(ctx, instance)
+	if err != nil && ctx.Err() != nil {
+		rollbackInstance(client, instance.GetName(), createdDisk, log)
+	}
+
+	return err
 }
 
 func buildInstance(options *options.Options) (*computepb.Instance, error) {
@@ -62,118 +230,400 @@ func buildInstance(options *options.Options) (*computepb.Instance, error) {
 	}
 
 	// generate ssh keys
-	publicKeyBase, err := ssh.GetPublicKeyBase(options.MachineFolder)
+	publicKey, err := loadPublicKey(options)
+	if err != nil {
+		return nil, err
+	}
+
+	bootDisk, err := buildBootDisk(options, int64(diskSize))
+	if err != nil {
+		return nil, err
+	}
+
+	additionalNetworkInterfaces, err := buildAdditionalNetworkInterfaces(options)
+	if err != nil {
+		return nil, err
+	}
+
+	startupScript, err := startup.Script(options)
 	if err != nil {
-		return nil, errors.Wrap(err, "generate public key")
+		return nil, errors.Wrap(err, "generate startup script")
 	}
 
-	publicKey, err := base64.StdEncoding.DecodeString(publicKeyBase)
+	metadata, err := mergeCustomMetadata([]*computepb.Items{
+		{
+			Key:   ptr.Ptr("ssh-keys"),
+			Value: ptr.Ptr(sshKeyEntry(publicKey)),
+		},
+		{
+			Key:   ptr.Ptr("startup-script"),
+			Value: ptr.Ptr(startupScript),
+		},
+		{
+			Key:   ptr.Ptr("enable-guest-attributes"),
+			Value: ptr.Ptr("TRUE"),
+		},
+	}, options)
 	if err != nil {
 		return nil, err
 	}
+	if options.BlockProjectSSHKeys {
+		// only the instance's own keys grant access
+		metadata = append(metadata, &computepb.Items{
+			Key:   ptr.Ptr("block-project-ssh-keys"),
+			Value: ptr.Ptr("TRUE"),
+		})
+	}
+	if options.NoPublicIP {
+		// later commands connect to the internal ip even if NO_PUBLIC_IP was only set by ORG_POLICY_MODE=adjust
+		metadata = append(metadata, &computepb.Items{
+			Key:   ptr.Ptr(noPublicIPKey),
+			Value: ptr.Ptr("TRUE"),
+		})
+	}
+	if options.UseGPUImage() {
+		// deep learning vm images install the matching nvidia driver on first boot
+		metadata = append(metadata, &computepb.Items{
+			Key:   ptr.Ptr("install-nvidia-driver"),
+			Value: ptr.Ptr("True"),
+		})
+	}
 
 	// generate instance object
 	instance := &computepb.Instance{
 		Metadata: &computepb.Metadata{
-			Items: []*computepb.Items{
-				{
-					Key:   ptr.Ptr("ssh-keys"),
-					Value: ptr.Ptr("devpod:" + string(publicKey)),
-				},
-			},
+			Items: metadata,
 		},
 		MachineType: ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s/machineTypes/%s", options.Project, options.Zone, options.MachineType)),
-		Disks: []*computepb.AttachedDisk{
+		Disks: append([]*computepb.AttachedDisk{
+			bootDisk,
+		}, buildDataDisks(options)...),
+		Tags: buildInstanceTags(options),
+		NetworkInterfaces: append([]*computepb.NetworkInterface{
+			buildNetworkInterface(options),
+		}, additionalNetworkInterfaces...),
+		NetworkPerformanceConfig: buildNetworkPerformanceConfig(options),
+		ServiceAccounts:          buildServiceAccounts(options),
+		GuestAccelerators:        buildGuestAccelerators(options),
+		Scheduling:               buildScheduling(options),
+		ResourcePolicies:         buildResourcePolicies(options),
+		Zone:                     ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s", options.Project, options.Zone)),
+		Name:                     ptr.Ptr(options.MachineID),
+	}
+	if options.ShieldedVM {
+		instance.ShieldedInstanceConfig = &computepb.ShieldedInstanceConfig{
+			EnableSecureBoot:          ptr.Ptr(true),
+			EnableVtpm:                ptr.Ptr(true),
+			EnableIntegrityMonitoring: ptr.Ptr(true),
+		}
+	}
+	if options.Hostname != "" {
+		instance.Hostname = ptr.Ptr(options.Hostname)
+	}
+	if len(options.Labels) > 0 {
+		instance.Labels = map[string]string{}
+		for key, value := range options.Labels {
+			instance.Labels[key] = value
+		}
+	}
+
+	return instance, nil
+}
+
+func buildBootDisk(options *options.Options, diskSize int64) (*computepb.AttachedDisk, error) {
+	source, err := gcloud.ParseDiskSource(sourceImage(options), options.Project)
+	if err != nil {
+		return nil, err
+	}
+	err = source.CheckAttachable(options.Project, options.Zone)
+	if err != nil {
+		return nil, err
+	}
+
+	// existing disks are attached as they are and outlive the instance
+	if source.Kind == gcloud.DiskSourceDisk {
+		return &computepb.AttachedDisk{
+			AutoDelete: ptr.Ptr(false),
+			Boot:       ptr.Ptr(true),
+			DeviceName: ptr.Ptr(options.MachineID),
+			Source:     ptr.Ptr(source.URL),
+		}, nil
+	}
+
+	initializeParams := &computepb.AttachedDiskInitializeParams{
+		DiskSizeGb: ptr.Ptr(diskSize),
+		DiskType:   ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s/diskTypes/%s", options.Project, options.Zone, options.DiskType)),
+	}
+	if options.ProvisionedIops > 0 {
+		initializeParams.ProvisionedIops = ptr.Ptr(int64(options.ProvisionedIops))
+	}
+	if source.Kind == gcloud.DiskSourceSnapshot {
+		initializeParams.SourceSnapshot = ptr.Ptr(source.URL)
+	} else {
+		initializeParams.SourceImage = ptr.Ptr(source.URL)
+	}
+
+	return &computepb.AttachedDisk{
+		AutoDelete:       ptr.Ptr(!retainBootDisk(options)),
+		Boot:             ptr.Ptr(true),
+		DeviceName:       ptr.Ptr(options.MachineID),
+		InitializeParams: initializeParams,
+	}, nil
+}
+
+// buildDataDisks returns the data disk or the local ssds that hold docker's data-root
+func buildDataDisks(options *options.Options) []*computepb.AttachedDisk {
+	if options.DataDiskSize > 0 {
+		return []*computepb.AttachedDisk{
 			{
-				AutoDelete: ptr.Ptr(true),
-				Boot:       ptr.Ptr(true),
-				DeviceName: ptr.Ptr(options.MachineID),
+				AutoDelete: ptr.Ptr(!retainBootDisk(options)),
+				DeviceName: ptr.Ptr(startup.DataDiskDeviceName),
 				InitializeParams: &computepb.AttachedDiskInitializeParams{
-					DiskSizeGb:  ptr.Ptr(int64(diskSize)),
-					DiskType:    ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s/diskTypes/pd-balanced", options.Project, options.Zone)),
-					SourceImage: ptr.Ptr(options.DiskImage),
+					DiskName:   ptr.Ptr(dataDiskName(options.MachineID)),
+					DiskSizeGb: ptr.Ptr(int64(options.DataDiskSize)),
+					DiskType:   ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s/diskTypes/%s", options.Project, options.Zone, options.DiskType)),
 				},
 			},
+		}
+	}
+
+	disks := []*computepb.AttachedDisk{}
+	for i := 0; i < options.LocalSSDCount; i++ {
+		disks = append(disks, &computepb.AttachedDisk{
+			AutoDelete: ptr.Ptr(true),
+			Interface:  ptr.Ptr("NVME"),
+			Type:       ptr.Ptr("SCRATCH"),
+			InitializeParams: &computepb.AttachedDiskInitializeParams{
+				DiskType: ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s/diskTypes/local-ssd", options.Project, options.Zone)),
+			},
+		})
+	}
+
+	return disks
+}
+
+// dataDiskName returns the name of the data disk of the given machine
+func dataDiskName(machineID string) string {
+	return options.SanitizeName(machineID + "-data")
+}
+
+// attachRetainedDataDisk attaches the data disk a deleted machine with the same id left behind
+// instead of creating a new one, so docker's images and volumes survive recreating the machine
+func attachRetainedDataDisk(ctx context.Context, client *gcloud.Client, instance *computepb.Instance, log log.Logger) error {
+	for _, attachedDisk := range instance.GetDisks() {
+		if attachedDisk.GetDeviceName() != startup.DataDiskDeviceName || attachedDisk.InitializeParams == nil {
+			continue
+		}
+
+		disk, err := client.GetDisk(ctx, attachedDisk.InitializeParams.GetDiskName())
+		if err != nil {
+			return err
+		} else if disk == nil {
+			return nil
+		} else if len(disk.GetUsers()) > 0 {
+			return fmt.Errorf("data disk %s is still attached to %s", disk.GetName(), path.Base(disk.GetUsers()[0]))
+		}
+
+		log.Infof("Reattaching retained data disk %s", disk.GetName())
+		attachedDisk.InitializeParams = nil
+		attachedDisk.Source = ptr.Ptr(disk.GetSelfLink())
+	}
+
+	return nil
+}
+
+func sourceImage(o *options.Options) string {
+	if o.ImageFamily != "" {
+		return gcloud.FamilyImage(o.Project, o.ImageFamily)
+	} else if o.DiskImage != "" {
+		return o.DiskImage
+	} else if o.UseGPUImage() {
+		return gcloud.FamilyImage(gcloud.GPUImageProject, gcloud.GPUImageFamily)
+	}
+
+	return options.DefaultDiskImage
+}
+
+func buildNetworkInterface(options *options.Options) *computepb.NetworkInterface {
+	networkInterface := &computepb.NetworkInterface{
+		Network:    normalizeNetworkID(options),
+		Subnetwork: normalizeSubnetworkID(options),
+	}
+	if options.StackType == "IPV4_IPV6" {
+		networkInterface.StackType = ptr.Ptr(options.StackType)
+	}
+	if options.NicType != "" {
+		networkInterface.NicType = ptr.Ptr(options.NicType)
+	}
+
+	// without a public ip the instance is only reachable from within the vpc
+	if options.NoPublicIP {
+		return networkInterface
+	}
+
+	networkInterface.AccessConfigs = []*computepb.AccessConfig{
+		{
+			Name:        ptr.Ptr("External NAT"),
+			NetworkTier: ptr.Ptr("STANDARD"),
 		},
-		Tags: buildInstanceTags(options),
-		NetworkInterfaces: []*computepb.NetworkInterface{
+	}
+	if options.StackType == "IPV4_IPV6" {
+		// external ipv6 is only available on the premium tier
+		networkInterface.Ipv6AccessConfigs = []*computepb.AccessConfig{
 			{
-				Network:    normalizeNetworkID(options),
-				Subnetwork: normalizeSubnetworkID(options),
-				AccessConfigs: []*computepb.AccessConfig{
-					{
-						Name:        ptr.Ptr("External NAT"),
-						NetworkTier: ptr.Ptr("STANDARD"),
-					},
-				},
+				Name:        ptr.Ptr("External IPv6"),
+				Type:        ptr.Ptr("DIRECT_IPV6"),
+				NetworkTier: ptr.Ptr("PREMIUM"),
 			},
-		},
-		Zone: ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s", options.Project, options.Zone)),
-		Name: ptr.Ptr(options.MachineID),
+		}
 	}
 
-	return instance, nil
+	return networkInterface
 }
 
-func buildInstanceTags(options *options.Options) *computepb.Tags {
-	if len(options.Tag) == 0 {
+func buildNetworkPerformanceConfig(options *options.Options) *computepb.NetworkPerformanceConfig {
+	if options.NetworkTier == "" {
 		return nil
 	}
 
-	return &computepb.Tags{Items: []string{options.Tag}}
+	return &computepb.NetworkPerformanceConfig{
+		TotalEgressBandwidthTier: ptr.Ptr(options.NetworkTier),
+	}
 }
 
-func normalizeNetworkID(options *options.Options) *string {
-	network := options.Network
-	project := options.Project
+func buildServiceAccounts(options *options.Options) []*computepb.ServiceAccount {
+	email := options.ServiceAccount
+	if email == "" {
+		// the ops agent, gcsfuse and the docker credential helper need credentials to write metrics
+		// and logs, read the bucket or pull images
+		if !options.InstallOpsAgent && options.GCSBucket == "" && !options.DockerCredentialHelper {
+			return nil
+		}
+
+		email = "default"
+	}
+
+	return []*computepb.ServiceAccount{
+		{
+			Email:  ptr.Ptr(email),
+			Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+		},
+	}
+}
 
-	if len(network) == 0 {
+func buildGuestAccelerators(options *options.Options) []*computepb.AcceleratorConfig {
+	if options.AcceleratorType == "" {
 		return nil
 	}
 
-	// projects/{{project}}/regions/{{region}}/subnetworks/{{name}}
-	if regexp.MustCompile("projects/([^/]+)/global/networks/([^/]+)").MatchString(network) {
-		return ptr.Ptr(network)
+	return []*computepb.AcceleratorConfig{
+		{
+			AcceleratorType:  ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s/acceleratorTypes/%s", options.Project, options.Zone, options.AcceleratorType)),
+			AcceleratorCount: ptr.Ptr(int32(options.AcceleratorCount)),
+		},
+	}
+}
+
+func buildScheduling(options *options.Options) *computepb.Scheduling {
+	if options.Spot {
+		return &computepb.Scheduling{
+			ProvisioningModel:         ptr.Ptr("SPOT"),
+			InstanceTerminationAction: ptr.Ptr(options.SpotTerminationAction),
+			AutomaticRestart:          ptr.Ptr(false),
+			OnHostMaintenance:         ptr.Ptr("TERMINATE"),
+		}
+	}
+
+	// instances with gpus or a placement policy can't be live migrated
+	onHostMaintenance := options.OnHostMaintenance
+	if onHostMaintenance == "" && (options.HasGPU() || options.PlacementPolicy != "") {
+		onHostMaintenance = "TERMINATE"
+	}
+	if onHostMaintenance == "" && options.AutomaticRestart {
+		return nil
 	}
 
-	// {{project}}/{{name}}
-	if regexp.MustCompile("([^/]+)/([^/]+)").MatchString(network) {
-		s := strings.Split(network, "/")
-		return ptr.Ptr(fmt.Sprintf("projects/%s/global/networks/%s", s[0], s[1]))
+	scheduling := &computepb.Scheduling{}
+	if onHostMaintenance != "" {
+		scheduling.OnHostMaintenance = ptr.Ptr(onHostMaintenance)
+	}
+	if !options.AutomaticRestart {
+		scheduling.AutomaticRestart = ptr.Ptr(false)
 	}
 
-	// {{name}}
-	return ptr.Ptr(fmt.Sprintf("projects/%s/global/networks/%s", project, network))
+	return scheduling
 }
 
-func normalizeSubnetworkID(options *options.Options) *string {
-	sn := strings.TrimSpace(options.Subnetwork)
+// buildResourcePolicies returns the placement policy of the instance, either given by its path or by
+// its name in the instance's region
+func buildResourcePolicies(options *options.Options) []string {
+	if options.PlacementPolicy == "" {
+		return nil
+	} else if strings.Contains(options.PlacementPolicy, "/") {
+		return []string{options.PlacementPolicy}
+	}
 
-	if len(sn) == 0 {
+	return []string{fmt.Sprintf("projects/%s/regions/%s/resourcePolicies/%s", options.Project, options.Region(), options.PlacementPolicy)}
+}
+
+// ensurePlacementPolicy creates the compact placement policy named by PLACEMENT_POLICY if it doesn't
+// exist yet, the machines that name it share it
+func ensurePlacementPolicy(ctx context.Context, client *gcloud.Client, options *options.Options) error {
+	if options.PlacementPolicy == "" || strings.Contains(options.PlacementPolicy, "/") {
 		return nil
 	}
 
-	project := options.Project
-	zone := options.Zone
-	region := zone[:strings.LastIndex(zone, "-")]
+	return client.EnsureCompactPlacementPolicy(ctx, options.PlacementPolicy)
+}
+
+// retainBootDisk checks if the boot disk should outlive the instance, which is the case with
+// KEEP_DISK_ON_DELETE and for spot instances that get deleted on preemption
+func retainBootDisk(options *options.Options) bool {
+	return options.KeepDiskOnDelete || (options.Spot && options.SpotTerminationAction == "DELETE")
+}
+
+// fromRetainedDisk returns a copy of the options that boots from the given retained boot disk
+func fromRetainedDisk(o *options.Options, disk *computepb.Disk) *options.Options {
+	diskOptions := *o
+	diskOptions.DiskImage = fmt.Sprintf("projects/%s/zones/%s/disks/%s", o.Project, o.Zone, disk.GetName())
+	diskOptions.ImageFamily = ""
+	return &diskOptions
+}
 
-	// projects/{{project}}/regions/{{region}}/subnetworks/{{name}}
-	if regexp.MustCompile("projects/([^/]+)/regions/([^/]+)/subnetworks/([^/]+)").MatchString(sn) {
-		return ptr.Ptr(sn)
+func buildInstanceTags(options *options.Options) *computepb.Tags {
+	if len(options.Tag) == 0 {
+		return nil
 	}
 
-	// {{project}}/{{region}}/{{name}}
-	if regexp.MustCompile("([^/]+)/([^/]+)/([^/]+)").MatchString(sn) {
-		s := strings.Split(sn, "/")
-		return ptr.Ptr(fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", s[0], s[1], s[2]))
+	return &computepb.Tags{Items: []string{options.Tag}}
+}
+
+func normalizeNetworkID(options *options.Options) *string {
+	return gcloud.NormalizeNetworkID(options.Network, networkProject(options))
+}
+
+func normalizeSubnetworkID(options *options.Options) *string {
+	return gcloud.NormalizeSubnetworkID(options.Subnetwork, networkProject(options), options.Zone)
+}
+
+// instanceSubnetwork returns the subnetwork the instance is created in, which is the subnetwork
+// called default in the zone's region of the default network if SUBNETWORK isn't set
+func instanceSubnetwork(options *options.Options) string {
+	subnetwork := normalizeSubnetworkID(options)
+	if subnetwork == nil {
+		defaultOptions := *options
+		defaultOptions.Subnetwork = "default"
+		subnetwork = normalizeSubnetworkID(&defaultOptions)
 	}
 
-	// {{region}}/{{name}}
-	if regexp.MustCompile("([^/]+)/([^/]+)").MatchString(sn) {
-		s := strings.Split(sn, "/")
-		return ptr.Ptr(fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", project, s[0], s[1]))
+	return *subnetwork
+}
+
+// networkProject returns the shared vpc host project if configured
+func networkProject(options *options.Options) string {
+	if options.NetworkProject != "" {
+		return options.NetworkProject
 	}
 
-	// {{name}}
-	return ptr.Ptr(fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", project, region, sn))
+	return options.Project
 }
@@ -17,13 +17,13 @@ func NewDeleteCmd() *cobra.Command {
 	deleteCmd := &cobra.Command{
 		Use:   "delete",
 		Short: "Delete an instance",
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
 			options, err := options.FromEnv(true)
 			if err != nil {
 				return err
 			}
 
-			return cmd.Run(context.Background(), options, log.Default)
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
 		},
 	}
 
@@ -32,11 +32,57 @@ func NewDeleteCmd() *cobra.Command {
 
 // Run runs the command logic
 func (cmd *DeleteCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
-	client, err := gcloud.NewClient(ctx, options.Project, options.Zone)
+	client, err := gcloud.NewClientFromOptions(ctx, options)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	return client.Delete(ctx, options.MachineID)
+	instance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	}
+
+	// collect the machine's keys before the instance metadata is gone
+	keys := machineSSHKeys(instance, options)
+	if instance != nil {
+		err = client.Delete(ctx, options.MachineID)
+		if err != nil {
+			return err
+		}
+	}
+	cleanupProjectSSHKeys(ctx, client, keys, log)
+
+	// the boot disk of spot instances outlives the instance, with KEEP_DISK_ON_DELETE it is kept on purpose
+	if options.KeepDiskOnDelete {
+		log.Infof("Keeping boot disk %s, the next create of %s reattaches it", options.MachineID, options.MachineID)
+		if options.DataDiskSize > 0 {
+			log.Infof("Keeping data disk %s", dataDiskName(options.MachineID))
+		}
+		return nil
+	} else if retainBootDisk(options) {
+		err = client.DeleteDisk(ctx, options.MachineID)
+		if err != nil {
+			return err
+		}
+
+		if options.DataDiskSize > 0 {
+			err = client.DeleteDisk(ctx, dataDiskName(options.MachineID))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// a retained disk keeps the project and name, so the next create finds it again
+	err = options.ForgetName()
+	if err != nil {
+		return err
+	}
+	err = options.ForgetZone()
+	if err != nil {
+		return err
+	}
+
+	return options.ForgetProject()
 }
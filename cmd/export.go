@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/export"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// ExportCmd holds the cmd flags
+type ExportCmd struct {
+	Format string
+}
+
+// NewExportCmd defines a command
+func NewExportCmd() *cobra.Command {
+	cmd := &ExportCmd{}
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the resources of an instance as terraform or gcloud script",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(true)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default.ErrorStreamOnly())
+		},
+	}
+	exportCmd.Flags().StringVar(&cmd.Format, "format", "terraform", "The output format, terraform or gcloud")
+
+	return exportCmd
+}
+
+// Run runs the command logic
+func (cmd *ExportCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	if cmd.Format != "terraform" && cmd.Format != "gcloud" {
+		return fmt.Errorf("unsupported format %s, needs to be one of terraform or gcloud", cmd.Format)
+	}
+
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	instance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		return fmt.Errorf("instance %s doesn't exist", options.MachineID)
+	}
+
+	resources := &export.Resources{
+		Project:  options.Project,
+		Zone:     options.Zone,
+		Instance: instance,
+	}
+	diskNames := []string{}
+	for _, attachedDisk := range instance.GetDisks() {
+		diskNames = append(diskNames, path.Base(attachedDisk.GetSource()))
+	}
+	resources.Disks, err = client.ListDisks(ctx, diskNames)
+	if err != nil {
+		return err
+	}
+
+	resources.Addresses, err = client.InstanceAddresses(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	resources.Firewalls, err = client.InstanceFirewalls(ctx, instance)
+	if err != nil {
+		// firewall rules often live in another project, the instance is still worth exporting
+		log.Warnf("Skipping firewall rules: %v", err)
+	}
+
+	if cmd.Format == "gcloud" {
+		_, err = fmt.Fprint(os.Stdout, export.Gcloud(resources))
+	} else {
+		_, err = fmt.Fprint(os.Stdout, export.Terraform(resources))
+	}
+
+	return err
+}
@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
@@ -18,13 +21,13 @@ func NewInitCmd() *cobra.Command {
 	initCmd := &cobra.Command{
 		Use:   "init",
 		Short: "Init an instance",
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
 			options, err := options.FromEnv(false)
 			if err != nil {
 				return err
 			}
 
-			return cmd.Run(context.Background(), options, log.Default)
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
 		},
 	}
 
@@ -33,11 +36,147 @@ func NewInitCmd() *cobra.Command {
 
 // Run runs the command logic
 func (cmd *InitCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
-	client, err := gcloud.NewClient(ctx, options.Project, options.Zone)
+	client, err := gcloud.NewClientFromOptions(ctx, options)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	return client.Init(ctx)
+	err = client.Init(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = checkProjectPermissions(ctx, client, log)
+	if err != nil {
+		return err
+	}
+
+	source, err := gcloud.ParseDiskSource(sourceImage(options), options.Project)
+	if err != nil {
+		return err
+	}
+	err = source.CheckAttachable(options.Project, options.Zone)
+	if err != nil {
+		return err
+	}
+
+	err = client.CheckDiskSourcePermissions(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	if options.NetworkProject != "" && options.NetworkProject != options.Project {
+		subnetwork := normalizeSubnetworkID(options)
+		if subnetwork == nil {
+			return fmt.Errorf("NETWORK_PROJECT requires SUBNETWORK to be set to a subnetwork shared by the host project")
+		}
+
+		err = client.CheckSubnetworkPermissions(ctx, *subnetwork, !options.NoPublicIP)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.NoPublicIP {
+		subnetwork := instanceSubnetwork(options)
+		err = client.CheckPrivateGoogleAccess(ctx, subnetwork)
+		if err != nil {
+			return err
+		}
+
+		err = checkCloudNAT(ctx, client, options, subnetwork, log)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.BlockProjectSSHKeys {
+		checkProjectSSHKeys(ctx, client, log)
+	}
+
+	checkFirewall(ctx, client, options, log)
+	return nil
+}
+
+// checkProjectPermissions makes sure the caller can manage instances in the client's project, which
+// can differ between machines. Failing checks are only logged, so they don't block environments
+// without access to the resource manager api.
+func checkProjectPermissions(ctx context.Context, client *gcloud.Client, log log.Logger) error {
+	missing, err := client.MissingProjectPermissions(ctx, client.Project, gcloud.InstancePermissions)
+	if err != nil {
+		log.Debugf("Skipping permission check in project %s: %v", client.Project, err)
+		return nil
+	} else if len(missing) > 0 {
+		return fmt.Errorf("missing permissions %s in project %s. Ask a project owner to grant you roles/compute.instanceAdmin.v1 in it", strings.Join(missing, ", "), client.Project)
+	}
+
+	return nil
+}
+
+// checkProjectSSHKeys warns about devpod keys left in the project-wide metadata
+func checkProjectSSHKeys(ctx context.Context, client *gcloud.Client, log log.Logger) {
+	metadata, err := client.ProjectMetadata(ctx)
+	if err != nil {
+		log.Debugf("Skipping project ssh key check: %v", err)
+		return
+	}
+
+	keys := projectSSHKeys(metadata.GetItems(), nil)
+	if len(keys) > 0 {
+		log.Warnf("The metadata of project %s contains %d devpod ssh key(s), they are blocked on devpod instances but grant access to all other instances. Remove the entries starting with devpod: from the ssh-keys project metadata.", client.Project, len(keys))
+	}
+}
+
+// checkCloudNAT makes sure instances without a public ip have outbound internet access and creates a
+// cloud nat with CREATE_CLOUD_NAT
+func checkCloudNAT(ctx context.Context, client *gcloud.Client, options *options.Options, subnetwork string, log log.Logger) error {
+	network, err := client.SubnetworkNetwork(ctx, subnetwork)
+	if err != nil {
+		return err
+	}
+
+	err = client.CheckCloudNAT(ctx, network, subnetwork)
+	if errors.Is(err, gcloud.ErrCloudNATCheckSkipped) {
+		log.Warnf("%v", err)
+		return nil
+	} else if errors.Is(err, gcloud.ErrNoCloudNAT) && options.CreateCloudNAT {
+		log.Infof("Creating cloud nat for subnetwork %s", subnetwork)
+		return client.CreateCloudNAT(ctx, network, subnetwork)
+	}
+
+	return err
+}
+
+// checkFirewall warns if the instance won't be reachable on port 22, either from the internet or
+// from internal addresses when going through a bastion or without a public ip. Hierarchical
+// policies or rules added later might still allow it, so init doesn't fail.
+func checkFirewall(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) {
+	network := normalizeNetworkID(options)
+	if network == nil {
+		subnetwork := normalizeSubnetworkID(options)
+		if subnetwork != nil {
+			networkURL, err := client.SubnetworkNetwork(ctx, *subnetwork)
+			if err != nil {
+				log.Warnf("Skipping firewall check: %v", err)
+				return
+			}
+			network = &networkURL
+		} else {
+			defaultOptions := *options
+			defaultOptions.Network = "default"
+			network = normalizeNetworkID(&defaultOptions)
+		}
+	}
+
+	tags := []string{}
+	if instanceTags := buildInstanceTags(options); instanceTags != nil {
+		tags = instanceTags.Items
+	}
+
+	publicSource := options.BastionHost == "" && !options.NoPublicIP
+	err := client.CheckSSHIngress(ctx, *network, tags, options.ServiceAccount, publicSource)
+	if err != nil {
+		log.Warnf("%v", err)
+	}
 }
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/base64"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ssh"
+	"github.com/pkg/errors"
+)
+
+// hasOwnKey returns true if the user brings their own key instead of the generated machine key
+func hasOwnKey(options *options.Options) bool {
+	return options.SSHPrivateKeyPath != "" || options.SSHPrivateKey != "" || options.SSHPublicKey != ""
+}
+
+// privateKeyFromOption returns the private key passed in SSH_PRIVATE_KEY
+func privateKeyFromOption(options *options.Options) ([]byte, error) {
+	return ssh.ParsePrivateKey([]byte(options.SSHPrivateKey))
+}
+
+// keyDir returns the folder of the machine's generated key pair. It is named after devpod's machine
+// id, which unlike the instance name doesn't depend on NAME_TEMPLATE.
+func keyDir(options *options.Options) (string, error) {
+	return ssh.MachineKeyDir(options.MachineFolder, options.DevPodMachineID, options.MachineID)
+}
+
+// loadPrivateKey returns the private key to connect with. It is empty if only SSH_PUBLIC_KEY is set,
+// in which case the ssh agent is used.
+func loadPrivateKey(options *options.Options) ([]byte, error) {
+	if options.SSHPrivateKeyPath != "" {
+		return ssh.ReadPrivateKey(options.SSHPrivateKeyPath)
+	} else if options.SSHPrivateKey != "" {
+		return privateKeyFromOption(options)
+	} else if options.SSHPublicKey != "" {
+		return nil, nil
+	}
+
+	dir, err := keyDir(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.GetPrivateKeyRawBase(dir, ssh.KeyType(options.SSHKeyType))
+}
+
+// loadPublicKey returns the public key to authorize on the instance in authorized_keys format
+func loadPublicKey(options *options.Options) ([]byte, error) {
+	if options.SSHPublicKey != "" {
+		return ssh.ReadPublicKey(options.SSHPublicKey)
+	}
+
+	if options.SSHPrivateKeyPath != "" {
+		// prefer the .pub file next to the private key
+		publicKey, err := ssh.ReadPublicKey(options.SSHPrivateKeyPath + ".pub")
+		if err == nil {
+			return publicKey, nil
+		}
+
+		privateKey, err := ssh.ReadPrivateKey(options.SSHPrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return ssh.PublicKeyFromPrivateKey(privateKey)
+	} else if options.SSHPrivateKey != "" {
+		privateKey, err := privateKeyFromOption(options)
+		if err != nil {
+			return nil, err
+		}
+
+		return ssh.PublicKeyFromPrivateKey(privateKey)
+	}
+
+	dir, err := keyDir(options)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyBase, err := ssh.GetPublicKeyBase(dir, ssh.KeyType(options.SSHKeyType))
+	if err != nil {
+		return nil, errors.Wrap(err, "generate public key")
+	}
+
+	return base64.StdEncoding.DecodeString(publicKeyBase)
+}
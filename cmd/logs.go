@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// LogsCmd holds the cmd flags
+type LogsCmd struct {
+	Follow bool
+	Source string
+	Since  time.Duration
+	Until  time.Duration
+}
+
+// NewLogsCmd defines a command
+func NewLogsCmd() *cobra.Command {
+	cmd := &LogsCmd{}
+	logsCmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Print the serial console output of an instance",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(true)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
+		},
+	}
+
+	logsCmd.Flags().BoolVarP(&cmd.Follow, "follow", "f", false, "If enabled will keep streaming new output")
+	logsCmd.Flags().StringVar(&cmd.Source, "source", "serial", "Where to read the logs from, either serial or cloud-logging")
+	logsCmd.Flags().DurationVar(&cmd.Since, "since", time.Hour, "How far back to query Cloud Logging")
+	logsCmd.Flags().DurationVar(&cmd.Until, "until", 0, "Only query Cloud Logging entries older than this, e.g. 30m")
+	return logsCmd
+}
+
+// Run runs the command logic
+func (cmd *LogsCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	switch cmd.Source {
+	case "serial":
+		return cmd.serialLogs(ctx, client, options, log)
+	case "cloud-logging":
+		return cmd.cloudLogs(ctx, client, options)
+	}
+
+	return fmt.Errorf("unsupported log source %s, needs to be one of serial or cloud-logging", cmd.Source)
+}
+
+func (cmd *LogsCmd) serialLogs(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) error {
+	var start int64
+	for {
+		output, err := client.GetSerialPortOutput(ctx, options.MachineID, start)
+		if err != nil {
+			return fmt.Errorf("get serial port output: %w", err)
+		}
+
+		// the api only keeps the last 1MB, so we might have missed some output
+		if output.GetStart() > start && start > 0 {
+			log.ErrorStreamOnly().Warnf("Skipped %d bytes of output that were already rotated out", output.GetStart()-start)
+		}
+
+		_, err = fmt.Fprint(os.Stdout, output.GetContents())
+		if err != nil {
+			return err
+		}
+
+		start = output.GetNext()
+		if !cmd.Follow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (cmd *LogsCmd) cloudLogs(ctx context.Context, client *gcloud.Client, options *options.Options) error {
+	// log entries are keyed by the instance id instead of the name
+	instance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		return fmt.Errorf("instance %s doesn't exist", options.MachineID)
+	}
+
+	if cmd.Until > 0 && cmd.Follow {
+		return fmt.Errorf("--until can't be combined with --follow")
+	} else if cmd.Until >= cmd.Since {
+		return fmt.Errorf("--until needs to be shorter than --since")
+	}
+
+	since := time.Now().Add(-cmd.Since)
+	until := time.Time{}
+	if cmd.Until > 0 {
+		until = time.Now().Add(-cmd.Until)
+	}
+
+	// entries can share a timestamp, so the last timestamp is queried again and the entries
+	// already printed for it are skipped
+	seen := map[string]bool{}
+	for {
+		entries, err := client.ListInstanceLogs(ctx, instance.GetId(), since, until)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if seen[entry.InsertID] {
+				continue
+			}
+
+			_, err = fmt.Fprintf(os.Stdout, "%s [%s] %s\n", entry.Timestamp.Format(time.RFC3339), entry.ShortLogName(), strings.TrimRight(entry.Message(), "\n"))
+			if err != nil {
+				return err
+			}
+
+			if !entry.Timestamp.Equal(since) {
+				since = entry.Timestamp
+				seen = map[string]bool{}
+			}
+			seen[entry.InsertID] = true
+		}
+
+		if !cmd.Follow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/startup"
+)
+
+// mergeCustomMetadata adds the user defined metadata to the provider's own metadata items and
+// fails if a key would be overwritten
+func mergeCustomMetadata(items []*computepb.Items, options *options.Options) ([]*computepb.Items, error) {
+	custom, err := parseCustomMetadata(options.CustomMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]bool{}
+	// stampInstance adds these after the custom metadata
+	for _, key := range stampKeys {
+		existing[key] = true
+	}
+	for _, item := range items {
+		existing[item.GetKey()] = true
+	}
+
+	for _, item := range custom {
+		if options.Templating {
+			value, err := startup.Render("CUSTOM_METADATA "+item.GetKey(), item.GetValue(), options)
+			if err != nil {
+				return nil, err
+			}
+			item.Value = ptr.Ptr(value)
+		}
+
+		if existing[item.GetKey()] {
+			return nil, fmt.Errorf("CUSTOM_METADATA key %s conflicts with metadata set by the provider or another CUSTOM_METADATA entry", item.GetKey())
+		}
+
+		existing[item.GetKey()] = true
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// parseCustomMetadata parses comma or newline separated key=value pairs. Values starting with @ are
+// read from the referenced file.
+func parseCustomMetadata(raw string) ([]*computepb.Items, error) {
+	items := []*computepb.Items{}
+	for _, entry := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid CUSTOM_METADATA entry %q, expected key=value or key=@file", entry)
+		}
+
+		if strings.HasPrefix(value, "@") {
+			out, err := os.ReadFile(strings.TrimPrefix(value, "@"))
+			if err != nil {
+				return nil, fmt.Errorf("read CUSTOM_METADATA file for key %s: %w", key, err)
+			}
+
+			value = string(out)
+		}
+
+		items = append(items, &computepb.Items{
+			Key:   ptr.Ptr(key),
+			Value: ptr.Ptr(value),
+		})
+	}
+
+	return items, nil
+}
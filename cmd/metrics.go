@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/metrics"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// MetricsCmd holds the cmd flags
+type MetricsCmd struct {
+	Output string
+}
+
+// NewMetricsCmd defines a command
+func NewMetricsCmd() *cobra.Command {
+	cmd := &MetricsCmd{}
+	metricsCmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "Print the metrics collected in METRICS_FILE",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			return cmd.Run(os.Stdout)
+		},
+	}
+	metricsCmd.Flags().StringVar(&cmd.Output, "output", "prometheus", "The output format, prometheus or summary")
+
+	return metricsCmd
+}
+
+// Run runs the command logic
+func (cmd *MetricsCmd) Run(w io.Writer) error {
+	if cmd.Output != "prometheus" && cmd.Output != "summary" {
+		return fmt.Errorf("unsupported output %s, needs to be one of prometheus or summary", cmd.Output)
+	} else if metrics.File() == "" {
+		return fmt.Errorf("metrics are disabled, set METRICS_FILE to collect them")
+	}
+
+	store, err := metrics.Load(metrics.File())
+	if err != nil {
+		return err
+	} else if cmd.Output == "prometheus" {
+		return store.WritePrometheus(w)
+	}
+
+	return printMetricsSummary(w, store)
+}
+
+// printMetricsSummary prints the number of runs, failures and the average duration of each command
+func printMetricsSummary(w io.Writer, store *metrics.Store) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMMAND\tRUNS\tFAILED\tAVG DURATION\tAVG READY")
+
+	commands := []string{}
+	for command := range store.CommandDurations {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	for _, command := range commands {
+		runs, failed, sum := uint64(0), uint64(0), 0.0
+		for result, histogram := range store.CommandDurations[command] {
+			runs += histogram.Count
+			sum += histogram.Sum
+			if result == metrics.ResultError {
+				failed += histogram.Count
+			}
+		}
+
+		ready := "-"
+		if histogram := store.InstanceReady[command]; histogram != nil && histogram.Count > 0 {
+			ready = averageDuration(histogram.Sum, histogram.Count)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", command, runs, failed, averageDuration(sum, runs), ready)
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	codes := []string{}
+	for code := range store.APIErrors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "API errors with status %s: %d\n", code, store.APIErrors[code])
+	}
+
+	return nil
+}
+
+func averageDuration(sum float64, count uint64) string {
+	if count == 0 {
+		return "-"
+	}
+
+	return (time.Duration(sum / float64(count) * float64(time.Second))).Round(100 * time.Millisecond).String()
+}
+
+// observeInstanceReady records how long the command took to bring up the instance if it waited
+// for the instance to become ready and succeeded
+func observeInstanceReady(command string, options *options.Options, start time.Time, err *error) {
+	if *err == nil && options.ReadyTimeout > 0 {
+		metrics.ObserveInstanceReady(command, time.Since(start))
+	}
+}
+
+// recordCommand adds the duration and result of the executed command to METRICS_FILE. Failures are
+// only logged, metrics never fail a command.
+func recordCommand(command string, err error, d time.Duration) {
+	if metrics.File() == "" || command == "metrics" {
+		return
+	}
+
+	result := metrics.ResultSuccess
+	if err != nil {
+		result = metrics.ResultError
+	}
+	metrics.ObserveCommand(command, result, d)
+
+	flushErr := metrics.Flush(metrics.File())
+	if flushErr != nil {
+		log.Default.ErrorStreamOnly().Debugf("Error writing metrics: %v", flushErr)
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+)
+
+// buildAdditionalNetworkInterfaces creates the secondary nics, which only get an internal ip
+func buildAdditionalNetworkInterfaces(options *options.Options) ([]*computepb.NetworkInterface, error) {
+	entries, err := parseAdditionalNetworkInterfaces(options.AdditionalNetworkInterfaces)
+	if err != nil {
+		return nil, err
+	}
+
+	// the gpu nics of a3 machines need to be complete and each one needs its own vpc
+	if gpuNICs := options.GPUNetworkInterfaces(); gpuNICs > 0 && len(entries) > 0 {
+		if len(entries) != gpuNICs {
+			return nil, fmt.Errorf("machine type %s needs %d ADDITIONAL_NETWORK_INTERFACES for GPUDirect, got %d", options.MachineType, gpuNICs, len(entries))
+		}
+
+		networks := map[string]bool{}
+		for _, entry := range entries {
+			network := entry["network"]
+			if network == "" {
+				network = entry["subnetwork"]
+			}
+			if networks[network] {
+				return nil, fmt.Errorf("the gpu nics of machine type %s need to be in different networks, %s is used twice", options.MachineType, network)
+			}
+			networks[network] = true
+		}
+	}
+
+	networkInterfaces := []*computepb.NetworkInterface{}
+	for _, entry := range entries {
+		project := entry["project"]
+		if project == "" {
+			project = options.Project
+		}
+
+		networkInterface := &computepb.NetworkInterface{
+			Network:    gcloud.NormalizeNetworkID(entry["network"], project),
+			Subnetwork: gcloud.NormalizeSubnetworkID(entry["subnetwork"], project, options.Zone),
+		}
+		if options.NicType != "" {
+			networkInterface.NicType = ptr.Ptr(options.NicType)
+		}
+
+		networkInterfaces = append(networkInterfaces, networkInterface)
+	}
+
+	return networkInterfaces, nil
+}
+
+// parseAdditionalNetworkInterfaces parses semicolon or newline separated nics, each given as comma
+// separated network=,subnetwork= and an optional project= for shared vpcs
+func parseAdditionalNetworkInterfaces(raw string) ([]map[string]string, error) {
+	entries := []map[string]string{}
+	for _, nic := range strings.FieldsFunc(raw, func(r rune) bool { return r == ';' || r == '\n' }) {
+		nic = strings.TrimSpace(nic)
+		if nic == "" {
+			continue
+		}
+
+		entry := map[string]string{}
+		for _, field := range strings.Split(nic, ",") {
+			key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+			if !found || (key != "network" && key != "subnetwork" && key != "project") {
+				return nil, fmt.Errorf("invalid ADDITIONAL_NETWORK_INTERFACES entry %q, expected network=...,subnetwork=...[,project=...]", nic)
+			}
+
+			entry[key] = strings.TrimSpace(value)
+		}
+
+		if entry["network"] == "" && entry["subnetwork"] == "" {
+			return nil, fmt.Errorf("invalid ADDITIONAL_NETWORK_INTERFACES entry %q, network or subnetwork is required", nic)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod/pkg/log"
+)
+
+// checkOrgPolicies looks up the org policy constraints that commonly reject new instances and explains
+// violations before the api does. With ORG_POLICY_MODE=adjust the options are changed to comply where
+// that is possible. Constraints that can't be looked up are skipped.
+func checkOrgPolicies(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) error {
+	if options.OrgPolicyMode == "off" {
+		return nil
+	}
+
+	adjust := options.OrgPolicyMode == "adjust"
+	policy := func(constraint string) *gcloud.OrgPolicy {
+		orgPolicy, err := client.EffectiveOrgPolicy(ctx, constraint)
+		if err != nil {
+			log.Debugf("Skipping org policy check: %v", err)
+			return nil
+		}
+
+		return orgPolicy
+	}
+
+	violations := []string{}
+	if !options.NoPublicIP {
+		instance := fmt.Sprintf("projects/%s/zones/%s/instances/%s", options.Project, options.Zone, options.MachineID)
+		if orgPolicy := policy(gcloud.ConstraintVMExternalIPAccess); orgPolicy != nil && !orgPolicy.Allows(instance) {
+			violation := fmt.Sprintf("%s doesn't allow an external ip for instance %s. Set NO_PUBLIC_IP=true and connect from within the vpc or through BASTION_HOST", gcloud.ConstraintVMExternalIPAccess, options.MachineID)
+			if adjust {
+				problem := withoutPublicIPProblem(ctx, client, options, log)
+				if problem == "" {
+					log.Warnf("Org policy %s doesn't allow an external ip, creating instance %s without one, reachable through BASTION_HOST", gcloud.ConstraintVMExternalIPAccess, options.MachineID)
+					options.NoPublicIP = true
+				} else {
+					violations = append(violations, violation+", it can't be dropped automatically because "+problem)
+				}
+			} else {
+				violations = append(violations, violation)
+			}
+		}
+	}
+
+	if !options.ShieldedVM {
+		if orgPolicy := policy(gcloud.ConstraintRequireShieldedVM); orgPolicy != nil && orgPolicy.Enforced() {
+			if adjust {
+				log.Warnf("Org policy %s is enforced, creating instance %s as a shielded vm", gcloud.ConstraintRequireShieldedVM, options.MachineID)
+				options.ShieldedVM = true
+			} else {
+				violations = append(violations, fmt.Sprintf("%s requires shielded vms. Set SHIELDED_VM=true and use an image that supports shielded vm", gcloud.ConstraintRequireShieldedVM))
+			}
+		}
+	}
+
+	source, err := gcloud.ParseDiskSource(sourceImage(options), options.Project)
+	if err == nil && source.Kind == gcloud.DiskSourceImage {
+		if orgPolicy := policy(gcloud.ConstraintTrustedImageProjects); orgPolicy != nil && !orgPolicy.Allows("projects/"+source.Project) {
+			violation := fmt.Sprintf("%s doesn't trust images of project %s", gcloud.ConstraintTrustedImageProjects, source.Project)
+			if allowed := orgPolicy.AllowedValues(); len(allowed) > 0 {
+				violation += fmt.Sprintf(". Set DISK_IMAGE or IMAGE_FAMILY to an image of %s", strings.Join(allowed, ", "))
+			}
+			violations = append(violations, violation)
+		}
+	}
+
+	if orgPolicy := policy(gcloud.ConstraintRequireOSLogin); orgPolicy != nil && orgPolicy.Enforced() {
+		violations = append(violations, fmt.Sprintf("%s makes instances ignore the ssh keys in their metadata, so the provider can't connect. Ask an org policy admin to exempt project %s", gcloud.ConstraintRequireOSLogin, options.Project))
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("instance %s would violate org policies:\n- %s", options.MachineID, strings.Join(violations, "\n- "))
+	}
+
+	return nil
+}
+
+// withoutPublicIPProblem explains why the instance wouldn't work without a public ip. The provider
+// needs a bastion to reach it and the instance a cloud nat to reach the internet.
+func withoutPublicIPProblem(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) string {
+	if options.BastionHost == "" {
+		return "BASTION_HOST isn't set"
+	}
+
+	subnetwork := instanceSubnetwork(options)
+	network, err := client.SubnetworkNetwork(ctx, subnetwork)
+	if err != nil {
+		return fmt.Sprintf("the network of subnetwork %s can't be looked up: %v", subnetwork, err)
+	}
+
+	err = client.CheckCloudNAT(ctx, network, subnetwork)
+	if errors.Is(err, gcloud.ErrCloudNATCheckSkipped) {
+		log.Warnf("%v", err)
+	} else if errors.Is(err, gcloud.ErrNoCloudNAT) {
+		return fmt.Sprintf("no cloud nat covers subnetwork %s", subnetwork)
+	} else if err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
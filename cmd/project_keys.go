@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ssh"
+	"github.com/loft-sh/devpod/pkg/log"
+)
+
+// sshKeysMetadataKeys are the current and the legacy metadata keys holding ssh keys
+var sshKeysMetadataKeys = []string{"ssh-keys", "sshKeys"}
+
+// machineSSHKeys returns the devpod key entries of the machine, from the instance metadata if it
+// still exists and from the machine's key pair
+func machineSSHKeys(instance *computepb.Instance, options *options.Options) []string {
+	keys := []string{}
+	if instance != nil {
+		for _, item := range instance.GetMetadata().GetItems() {
+			if item.GetKey() != "ssh-keys" {
+				continue
+			}
+
+			for _, key := range splitSSHKeys(item.GetValue()) {
+				if strings.HasPrefix(strings.TrimSpace(key), "devpod:") {
+					keys = append(keys, strings.TrimSpace(key))
+				}
+			}
+		}
+	}
+
+	if !hasOwnKey(options) {
+		dir, err := keyDir(options)
+		if err == nil {
+			publicKey, err := ssh.ReadPublicKey(filepath.Join(dir, ssh.DevPodSSHPublicKeyFile))
+			if err == nil {
+				keys = append(keys, sshKeyEntry(publicKey))
+			}
+		}
+	}
+
+	return keys
+}
+
+// cleanupProjectSSHKeys removes the given devpod keys from the project-wide metadata, where older
+// versions or manual setups might have put them. Failures are only reported, the keys don't grant
+// access to instances that block project keys.
+func cleanupProjectSSHKeys(ctx context.Context, client *gcloud.Client, keys []string, log log.Logger) {
+	if len(keys) == 0 {
+		return
+	}
+
+	metadata, err := client.ProjectMetadata(ctx)
+	if err != nil {
+		log.Debugf("Skipping project ssh key cleanup: %v", err)
+		return
+	} else if len(projectSSHKeys(metadata.GetItems(), keys)) == 0 {
+		return
+	}
+
+	log.Infof("Removing ssh keys of %s from the project metadata", client.Project)
+	err = client.UpdateProjectMetadata(ctx, func(items []*computepb.Items) []*computepb.Items {
+		for _, item := range items {
+			if !isSSHKeysItem(item) {
+				continue
+			}
+
+			remaining := splitSSHKeys(item.GetValue())
+			for _, key := range keys {
+				remaining = removeSSHKey(remaining, key)
+			}
+			item.Value = ptr.Ptr(strings.Join(remaining, "\n"))
+		}
+
+		return items
+	})
+	if err != nil {
+		log.Warnf("Error removing ssh keys from the project metadata, please remove them manually: %v", err)
+	}
+}
+
+// projectSSHKeys returns the entries of the project-wide ssh keys that are one of the given keys or,
+// without keys, any devpod key
+func projectSSHKeys(items []*computepb.Items, keys []string) []string {
+	found := []string{}
+	for _, item := range items {
+		if !isSSHKeysItem(item) {
+			continue
+		}
+
+		for _, entry := range splitSSHKeys(item.GetValue()) {
+			entry = strings.TrimSpace(entry)
+			if len(keys) == 0 && strings.HasPrefix(entry, "devpod:") {
+				found = append(found, entry)
+				continue
+			}
+
+			for _, key := range keys {
+				if entry == key {
+					found = append(found, entry)
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+func isSSHKeysItem(item *computepb.Items) bool {
+	for _, key := range sshKeysMetadataKeys {
+		if item.GetKey() == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/shell"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ssh"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/startup"
+	"github.com/loft-sh/devpod/pkg/log"
+)
+
+// waitUntilReady blocks until docker and the devpod agent are available on the instance
+func waitUntilReady(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) error {
+	if options.ReadyTimeout <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, options.ReadyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	log.Infof("Waiting for docker and the devpod agent to become ready")
+	for {
+		// fail early if the startup script reported an error
+		phase, err := client.GetGuestAttribute(ctx, options.MachineID, startup.PhaseKey)
+		if err == nil && strings.HasPrefix(phase, startup.PhaseErrorPrefix) {
+			return fmt.Errorf("provisioning of instance %s failed: %s", options.MachineID, strings.TrimPrefix(phase, startup.PhaseErrorPrefix))
+		} else if phase != "" {
+			log.Infof("Provisioning phase: %s", phase)
+		}
+
+		err = probeReady(ctx, client, options)
+		if err == nil {
+			log.Donef("Instance is ready after %s", time.Since(start).Round(time.Second))
+			return nil
+		}
+
+		log.Infof("Instance not ready yet (%s): %v", time.Since(start).Round(time.Second), err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s waiting for instance to become ready: %w", options.ReadyTimeout, err)
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func probeReady(ctx context.Context, client *gcloud.Client, options *options.Options) error {
+	sshClient, err := newSSHClient(ctx, client, options)
+	if err != nil {
+		return fmt.Errorf("ssh is not reachable: %w", err)
+	}
+	defer sshClient.Close()
+
+	probe := fmt.Sprintf(`if ! sudo -n docker info >/dev/null 2>&1; then echo "docker is not running"; exit 1; fi
+if [ ! -x %[1]s ]; then echo "devpod agent is not installed"; exit 1; fi
+if ! timeout 30 %[1]s version >/dev/null 2>&1; then echo "devpod agent is not responding"; exit 1; fi`, shell.Quote(options.AgentPath+"/devpod"))
+
+	out := &bytes.Buffer{}
+	err = ssh.Run(ctx, sshClient, probe, nil, out, out)
+	if err != nil {
+		if msg := strings.TrimSpace(out.String()); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// waitForSSH blocks until the instance accepts ssh connections with the machine's key
+func waitForSSH(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) error {
+	if options.ReadyTimeout <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, options.ReadyTimeout)
+	defer cancel()
+
+	start := time.Now()
+	backoff := time.Second
+	log.Infof("Waiting for ssh to become reachable")
+	for {
+		err := probeSSH(ctx, client, options)
+		if err == nil {
+			log.Donef("SSH is reachable after %s", time.Since(start).Round(time.Second))
+			return nil
+		}
+
+		log.Infof("SSH not reachable yet (%s), retrying in %s: %v", time.Since(start).Round(time.Second), backoff, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s waiting for ssh: %w", options.ReadyTimeout, err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > 15*time.Second {
+			backoff = 15 * time.Second
+		}
+	}
+}
+
+func probeSSH(ctx context.Context, client *gcloud.Client, options *options.Options) error {
+	sshClient, err := newSSHClient(ctx, client, options)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	return ssh.Run(ctx, sshClient, "true", nil, io.Discard, io.Discard)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// reconcileInstance brings an already existing instance in line with the desired one. Labels, network
+// tags and metadata are updated in place.
+func reconcileInstance(ctx context.Context, client *gcloud.Client, existing, desired *computepb.Instance, log log.Logger) error {
+	// labels
+	labels := map[string]string{}
+	changed := false
+	for k, v := range existing.GetLabels() {
+		labels[k] = v
+	}
+	for k, v := range desired.GetLabels() {
+		if labels[k] != v {
+			labels[k] = v
+			changed = true
+		}
+	}
+	if changed {
+		log.Debugf("Updating labels of instance %s", existing.GetName())
+		err := client.SetLabels(ctx, existing, labels)
+		if err != nil {
+			return errors.Wrap(err, "update labels")
+		}
+	}
+
+	// network tags
+	if !equalTags(existing.GetTags().GetItems(), desired.GetTags().GetItems()) {
+		log.Debugf("Updating network tags of instance %s", existing.GetName())
+		err := client.SetTags(ctx, existing, desired.GetTags().GetItems())
+		if err != nil {
+			return errors.Wrap(err, "update network tags")
+		}
+	}
+
+	// metadata, which includes the ssh key and startup script
+	if !containsMetadata(existing.GetMetadata().GetItems(), desired.GetMetadata().GetItems()) {
+		log.Debugf("Updating metadata of instance %s", existing.GetName())
+		err := client.UpdateMetadata(ctx, existing.GetName(), func(items []*computepb.Items) []*computepb.Items {
+			return mergeMetadataItems(items, desired.GetMetadata().GetItems())
+		})
+		if err != nil {
+			return errors.Wrap(err, "update metadata")
+		}
+	}
+
+	return nil
+}
+
+// reconcileMachineType changes the machine type of a stopped instance to the desired one, running
+// instances need to be stopped first
+func reconcileMachineType(ctx context.Context, client *gcloud.Client, existing, desired *computepb.Instance, log log.Logger) error {
+	if path.Base(existing.GetMachineType()) == path.Base(desired.GetMachineType()) {
+		return nil
+	} else if existing.GetStatus() != "TERMINATED" {
+		return fmt.Errorf("instance %s has machine type %s instead of %s, stop the machine and run update to change it", existing.GetName(), path.Base(existing.GetMachineType()), path.Base(desired.GetMachineType()))
+	}
+
+	log.Infof("Updating machine type of instance %s to %s", existing.GetName(), path.Base(desired.GetMachineType()))
+	return client.SetMachineType(ctx, existing.GetName(), desired.GetMachineType())
+}
+
+func equalTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containsMetadata returns true if all wanted items are set to the same value
+func containsMetadata(items, wanted []*computepb.Items) bool {
+	values := map[string]string{}
+	for _, item := range items {
+		values[item.GetKey()] = item.GetValue()
+	}
+
+	for _, item := range wanted {
+		value, ok := values[item.GetKey()]
+		if !ok || value != item.GetValue() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mergeMetadataItems overwrites the items with the wanted ones and keeps all others
+func mergeMetadataItems(items, wanted []*computepb.Items) []*computepb.Items {
+	retItems := []*computepb.Items{}
+	for _, item := range items {
+		if hasMetadataKey(wanted, item.GetKey()) {
+			continue
+		}
+
+		retItems = append(retItems, item)
+	}
+
+	return append(retItems, wanted...)
+}
+
+func hasMetadataKey(items []*computepb.Items, key string) bool {
+	for _, item := range items {
+		if item.GetKey() == key {
+			return true
+		}
+	}
+
+	return false
+}
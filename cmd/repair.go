@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/shell"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ssh"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/startup"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RepairCmd holds the cmd flags
+type RepairCmd struct {
+	DryRun bool
+}
+
+// NewRepairCmd defines a command
+func NewRepairCmd() *cobra.Command {
+	cmd := &RepairCmd{}
+	repairCmd := &cobra.Command{
+		Use:   "repair",
+		Short: "Diagnose an instance and fix what keeps the workspace from working",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(true)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
+		},
+	}
+	repairCmd.Flags().BoolVar(&cmd.DryRun, "dry-run", false, "Only report the problems without fixing them")
+
+	return repairCmd
+}
+
+// Run runs the command logic
+func (cmd *RepairCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	instance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		if options.Spot && options.SpotAutoRecover {
+			return fmt.Errorf("instance %s doesn't exist, start recreates it from its retained boot disk", options.MachineID)
+		}
+
+		return fmt.Errorf("instance %s doesn't exist, nothing to repair, please recreate the machine", options.MachineID)
+	}
+
+	r := &repairer{dryRun: cmd.DryRun, log: log}
+
+	// the instance needs to run
+	switch instance.GetStatus() {
+	case "RUNNING", "PROVISIONING", "STAGING":
+		log.Donef("Instance %s is %s", options.MachineID, strings.ToLower(instance.GetStatus()))
+	case "TERMINATED", "SUSPENDED":
+		err = r.fix(fmt.Sprintf("Instance %s is %s", options.MachineID, strings.ToLower(instance.GetStatus())), func() error {
+			return client.Start(ctx, options.MachineID)
+		})
+		if err != nil {
+			return errors.Wrap(err, "start instance")
+		}
+	default:
+		return fmt.Errorf("instance %s is %s, run repair again once it stopped", options.MachineID, strings.ToLower(instance.GetStatus()))
+	}
+
+	// the machine's key has to be in the instance metadata
+	publicKey, err := loadPublicKey(options)
+	if err != nil {
+		return err
+	}
+	if !hasSSHKey(instance, sshKeyEntry(publicKey)) {
+		err = r.fix("SSH key of the machine is missing in the instance metadata", func() error {
+			return updateSSHKeys(ctx, client, options.MachineID, func(keys []string) []string {
+				return append(keys, sshKeyEntry(publicKey))
+			})
+		})
+		if err != nil {
+			return errors.Wrap(err, "add ssh key")
+		}
+	} else {
+		log.Donef("SSH key is present in the instance metadata")
+	}
+
+	// an outdated startup script is replaced before it is run again
+	desired, err := buildInstance(options)
+	if err != nil {
+		return err
+	}
+	startupScript := metadataItem(desired.GetMetadata().GetItems(), "startup-script")
+	outdatedScript := metadataItem(instance.GetMetadata().GetItems(), "startup-script").GetValue() != startupScript.GetValue()
+	if outdatedScript {
+		err = r.fix("Startup script of the instance is outdated", func() error {
+			return client.UpdateMetadata(ctx, options.MachineID, func(items []*computepb.Items) []*computepb.Items {
+				return mergeMetadataItems(items, []*computepb.Items{startupScript})
+			})
+		})
+		if err != nil {
+			return errors.Wrap(err, "update startup script")
+		}
+	}
+	if r.dryRun && (instance.GetStatus() != "RUNNING" || r.problems > 0) {
+		return r.report(options.MachineID)
+	}
+
+	// ssh has to be reachable to check the inside of the instance
+	err = waitForSSH(ctx, client, options, log)
+	if err != nil {
+		return fmt.Errorf("ssh is not reachable, check the firewall rules and the logs command: %w", err)
+	}
+	log.Donef("SSH is reachable")
+
+	rerunStartup := outdatedScript
+	phase, err := client.GetGuestAttribute(ctx, options.MachineID, startup.PhaseKey)
+	if err == nil && strings.HasPrefix(phase, startup.PhaseErrorPrefix) {
+		log.Warnf("Startup script failed: %s", strings.TrimPrefix(phase, startup.PhaseErrorPrefix))
+		rerunStartup = true
+	}
+
+	err = probeReady(ctx, client, options)
+	if err != nil && strings.Contains(err.Error(), "docker is not running") {
+		err = r.fix("Docker is not running", func() error {
+			return runOnInstance(ctx, client, options, "sudo systemctl restart docker")
+		})
+		if err != nil {
+			return errors.Wrap(err, "restart docker")
+		} else if r.dryRun {
+			return r.report(options.MachineID)
+		}
+		err = probeReady(ctx, client, options)
+	}
+	if err != nil && strings.Contains(err.Error(), "devpod agent is not responding") {
+		// devpod starts the agent again on its next connection, the startup script downloads it again
+		err = r.fix("Devpod agent is not responding", func() error {
+			return runOnInstance(ctx, client, options, fmt.Sprintf("sudo pkill -x devpod || true; sudo rm -f %s", shell.Quote(options.AgentPath+"/devpod")))
+		})
+		if err != nil {
+			return errors.Wrap(err, "restart devpod agent")
+		} else if r.dryRun {
+			return r.report(options.MachineID)
+		}
+		rerunStartup = true
+	} else if err != nil {
+		log.Warnf("Instance is not ready: %v", err)
+		rerunStartup = true
+	} else if !rerunStartup {
+		log.Donef("Docker and the devpod agent are ready")
+	}
+
+	if rerunStartup {
+		err = r.fix("Provisioning is incomplete", func() error {
+			return runOnInstance(ctx, client, options, "sudo google_metadata_script_runner startup")
+		})
+		if err != nil {
+			return errors.Wrap(err, "run startup script")
+		}
+
+		if !r.dryRun {
+			err = waitUntilReady(ctx, client, options, log)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return r.report(options.MachineID)
+}
+
+// repairer fixes the problems it is told about, in dry run mode it only reports them
+type repairer struct {
+	dryRun bool
+	log    log.Logger
+
+	problems int
+}
+
+func (r *repairer) fix(problem string, fix func() error) error {
+	r.problems++
+	if r.dryRun {
+		r.log.Warnf("%s", problem)
+		return nil
+	}
+
+	r.log.Warnf("%s, repairing it", problem)
+	return fix()
+}
+
+func (r *repairer) report(name string) error {
+	if r.problems == 0 {
+		r.log.Donef("Found no problems with instance %s", name)
+	} else if r.dryRun {
+		return fmt.Errorf("found %d problem(s) with instance %s, run repair without --dry-run to fix them", r.problems, name)
+	} else {
+		r.log.Donef("Repaired %d problem(s) of instance %s", r.problems, name)
+	}
+
+	return nil
+}
+
+// runOnInstance runs the command on the instance and streams its output to stderr
+func runOnInstance(ctx context.Context, client *gcloud.Client, options *options.Options, command string) error {
+	sshClient, err := newSSHClient(ctx, client, options)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	return ssh.Run(ctx, sshClient, command, nil, os.Stderr, os.Stderr)
+}
+
+func hasSSHKey(instance *computepb.Instance, entry string) bool {
+	for _, key := range splitSSHKeys(metadataItem(instance.GetMetadata().GetItems(), "ssh-keys").GetValue()) {
+		if strings.TrimSpace(key) == entry {
+			return true
+		}
+	}
+
+	return false
+}
+
+func metadataItem(items []*computepb.Items, key string) *computepb.Items {
+	for _, item := range items {
+		if item.GetKey() == key {
+			return item
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RestoreCmd holds the cmd flags
+type RestoreCmd struct {
+	Name    string
+	Project string
+	Zone    string
+}
+
+// NewRestoreCmd defines a command
+func NewRestoreCmd() *cobra.Command {
+	cmd := &RestoreCmd{}
+	restoreCmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Recreate the instance from a machine image created by backup",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(true)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
+		},
+	}
+
+	restoreCmd.Flags().StringVar(&cmd.Name, "name", "", "The name of the machine image, defaults to the latest backup of the machine")
+	restoreCmd.Flags().StringVar(&cmd.Project, "project", "", "The project to restore the instance in, defaults to PROJECT")
+	restoreCmd.Flags().StringVar(&cmd.Zone, "zone", "", "The zone to restore the instance in, defaults to ZONE")
+	return restoreCmd
+}
+
+// Run runs the command logic
+func (cmd *RestoreCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	name := cmd.Name
+	if name == "" {
+		machineImages, err := client.ListMachineImages(ctx, options.MachineID)
+		if err != nil {
+			return err
+		} else if len(machineImages) == 0 {
+			return fmt.Errorf("couldn't find a backup of %s in project %s", options.MachineID, options.Project)
+		}
+
+		name = machineImages[len(machineImages)-1].GetName()
+	}
+	machineImage := fmt.Sprintf("projects/%s/global/machineImages/%s", options.Project, name)
+
+	targetOptions := *options
+	if cmd.Project != "" {
+		targetOptions.Project = cmd.Project
+	}
+	if cmd.Zone != "" {
+		targetOptions.Zone = cmd.Zone
+	}
+
+	targetClient, err := gcloud.NewClientFromOptions(ctx, &targetOptions)
+	if err != nil {
+		return err
+	}
+	defer targetClient.Close()
+
+	existing, err := targetClient.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	} else if existing != nil {
+		return fmt.Errorf("instance %s already exists in zone %s of project %s, delete it first or restore into another zone", options.MachineID, targetOptions.Zone, targetOptions.Project)
+	}
+
+	instance := &computepb.Instance{
+		Name: ptr.Ptr(options.MachineID),
+	}
+
+	// the subnetwork stored in the machine image only exists in its original region and project
+	moved := targetOptions.Project != options.Project || targetOptions.Region() != options.Region()
+	if moved {
+		instance.NetworkInterfaces = []*computepb.NetworkInterface{buildNetworkInterface(&targetOptions)}
+	}
+
+	log.Infof("Restoring instance %s from %s into zone %s of project %s", options.MachineID, machineImage, targetOptions.Zone, targetOptions.Project)
+	err = targetClient.CreateFromMachineImage(ctx, instance, machineImage)
+	if err != nil {
+		return errors.Wrap(err, "create instance from machine image")
+	}
+
+	// later commands of the machine find it in the target project and zone
+	err = targetOptions.RecordProject()
+	if err != nil {
+		return err
+	}
+	if targetOptions.Zone != options.Zone {
+		err = targetOptions.RecordZone()
+		if err != nil {
+			return err
+		}
+	}
+
+	return waitUntilReady(ctx, targetClient, &targetOptions, log)
+}
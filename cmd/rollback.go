@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod/pkg/log"
+)
+
+// rollbackInstance deletes the instance and, if given, the separately created boot disk after the
+// user aborted a create, so no billed resources are left behind. The command's context is already
+// cancelled at this point, so the cleanup runs with its own timeout.
+func rollbackInstance(client *gcloud.Client, name, disk string, log log.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	instance, err := client.Get(ctx, name)
+	if err != nil {
+		log.Errorf("Error rolling back instance %s, please delete it manually: %v", name, err)
+	} else if instance != nil {
+		log.Infof("Deleting partially created instance %s", name)
+		err = client.Delete(ctx, name)
+		if err != nil {
+			log.Errorf("Error deleting instance %s, please delete it manually: %v", name, err)
+		}
+	}
+
+	if disk != "" {
+		log.Infof("Deleting partially created disk %s", disk)
+		err = client.DeleteDisk(ctx, disk)
+		if err != nil {
+			log.Errorf("Error deleting disk %s, please delete it manually: %v", disk, err)
+		}
+	}
+}
@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"context"
 	log2 "github.com/loft-sh/devpod/pkg/log"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh"
 	"os"
 	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 // NewRootCmd returns a new root command
 func NewRootCmd() *cobra.Command {
+	machineFolder := ""
 	gcloudCmd := &cobra.Command{
 		Use:           "devpod-provider-gcloud",
 		Short:         "gcloud Provider commands",
@@ -18,9 +23,14 @@ func NewRootCmd() *cobra.Command {
 
 		PersistentPreRunE: func(cobraCmd *cobra.Command, args []string) error {
 			log2.Default.MakeRaw()
+			if machineFolder != "" {
+				return os.Setenv("MACHINE_FOLDER_OVERRIDE", machineFolder)
+			}
+
 			return nil
 		},
 	}
+	gcloudCmd.PersistentFlags().StringVar(&machineFolder, "machine-folder", "", "Store the machine's keys in this folder instead of MACHINE_FOLDER")
 
 	return gcloudCmd
 }
@@ -32,7 +42,14 @@ func Execute() {
 	rootCmd := BuildRoot()
 
 	// execute command
-	err := rootCmd.Execute()
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	start := time.Now()
+	err := rootCmd.ExecuteContext(ctx)
+	if executedCmd, _, findErr := rootCmd.Find(os.Args[1:]); findErr == nil && executedCmd != rootCmd {
+		recordCommand(executedCmd.Name(), err, time.Since(start))
+	}
 	if err != nil {
 		if exitErr, ok := err.(*ssh.ExitError); ok {
 			os.Exit(exitErr.ExitStatus())
@@ -44,10 +61,33 @@ func Execute() {
 			os.Exit(exitErr.ExitCode())
 		}
 
+		if handleAuthError(err, log2.Default.ErrorStreamOnly()) {
+			os.Exit(1)
+		}
+
 		log2.Default.Fatal(err)
 	}
 }
 
+// signalContext returns a context that is cancelled on the first SIGINT or SIGTERM, so running
+// commands can clean up. A second signal exits right away.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log2.Default.ErrorStreamOnly().Warnf("Aborting, press Ctrl-C again to exit immediately")
+		cancel()
+
+		<-signals
+		os.Exit(130)
+	}()
+
+	return ctx, cancel
+}
+
 // BuildRoot creates a new root command from the
 func BuildRoot() *cobra.Command {
 	rootCmd := NewRootCmd()
@@ -60,5 +100,16 @@ func BuildRoot() *cobra.Command {
 	rootCmd.AddCommand(NewCommandCmd())
 	rootCmd.AddCommand(NewTokenCmd())
 	rootCmd.AddCommand(NewInitCmd())
+	rootCmd.AddCommand(NewBakeImageCmd())
+	rootCmd.AddCommand(NewWarmPoolCmd())
+	rootCmd.AddCommand(NewLogsCmd())
+	rootCmd.AddCommand(NewRotateKeysCmd())
+	rootCmd.AddCommand(NewUpdateCmd())
+	rootCmd.AddCommand(NewExportCmd())
+	rootCmd.AddCommand(NewBackupCmd())
+	rootCmd.AddCommand(NewRestoreCmd())
+	rootCmd.AddCommand(NewRepairCmd())
+	rootCmd.AddCommand(NewMetricsCmd())
+	rootCmd.AddCommand(NewSSHCmd())
 	return rootCmd
 }
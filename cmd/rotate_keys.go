@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ssh"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// RotateKeysCmd holds the cmd flags
+type RotateKeysCmd struct {
+	Timeout time.Duration
+}
+
+// NewRotateKeysCmd defines a command
+func NewRotateKeysCmd() *cobra.Command {
+	cmd := &RotateKeysCmd{}
+	rotateKeysCmd := &cobra.Command{
+		Use:   "rotate-keys",
+		Short: "Replace the ssh key pair of an instance",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(true)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
+		},
+	}
+	rotateKeysCmd.Flags().DurationVar(&cmd.Timeout, "timeout", 2*time.Minute, "How long to wait for the instance to accept the new key")
+
+	return rotateKeysCmd
+}
+
+// Run runs the command logic
+func (cmd *RotateKeysCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return rotateKeys(ctx, client, options, cmd.Timeout, log)
+}
+
+// rotateKeysIfExpired rotates the machine's key pair if it is older than SSH_KEY_ROTATION_DAYS or
+// doesn't have the type configured in SSH_KEY_TYPE
+func rotateKeysIfExpired(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) error {
+	if hasOwnKey(options) {
+		return nil
+	}
+
+	dir, err := keyDir(options)
+	if err != nil {
+		return err
+	}
+
+	keyType, err := ssh.GetKeyType(dir)
+	if err != nil {
+		return err
+	} else if keyType != ssh.KeyType(options.SSHKeyType) {
+		log.Infof("SSH key of %s is of type %s instead of %s, rotating it", options.MachineID, keyType, options.SSHKeyType)
+		return rotateKeys(ctx, client, options, 2*time.Minute, log)
+	}
+
+	if options.SSHKeyRotationDays <= 0 {
+		return nil
+	}
+
+	age, err := ssh.KeyAge(dir)
+	if err != nil {
+		return err
+	} else if age < time.Duration(options.SSHKeyRotationDays)*24*time.Hour {
+		return nil
+	}
+
+	log.Infof("SSH key of %s is older than %d days, rotating it", options.MachineID, options.SSHKeyRotationDays)
+	return rotateKeys(ctx, client, options, 2*time.Minute, log)
+}
+
+// rotateKeys adds a new public key to the instance, verifies it and only then replaces the local
+// key pair and removes the old public key from the instance
+func rotateKeys(ctx context.Context, client *gcloud.Client, options *options.Options, timeout time.Duration, log log.Logger) error {
+	if hasOwnKey(options) {
+		return fmt.Errorf("the machine uses SSH_PRIVATE_KEY_PATH or SSH_PUBLIC_KEY, rotate that key yourself")
+	}
+
+	dir, err := keyDir(options)
+	if err != nil {
+		return err
+	}
+
+	oldPublicKeyBase, err := ssh.GetPublicKeyBase(dir, ssh.KeyType(options.SSHKeyType))
+	if err != nil {
+		return err
+	}
+	oldPublicKey, err := base64.StdEncoding.DecodeString(oldPublicKeyBase)
+	if err != nil {
+		return err
+	}
+
+	privateKey, publicKey, err := ssh.GenerateKeyPair(ssh.KeyType(options.SSHKeyType))
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Adding new public key to instance %s", options.MachineID)
+	err = updateSSHKeys(ctx, client, options.MachineID, func(keys []string) []string {
+		return append(keys, sshKeyEntry(publicKey))
+	})
+	if err != nil {
+		return errors.Wrap(err, "add public key")
+	}
+
+	err = verifySSHKey(ctx, client, options, privateKey, timeout)
+	if err != nil {
+		// leave the old key in place, the machine stays reachable with it
+		_ = updateSSHKeys(ctx, client, options.MachineID, func(keys []string) []string {
+			return removeSSHKey(keys, sshKeyEntry(publicKey))
+		})
+		return errors.Wrap(err, "verify new key")
+	}
+
+	err = ssh.WriteKeyPair(dir, privateKey, publicKey, ssh.KeyType(options.SSHKeyType))
+	if err != nil {
+		return errors.Wrap(err, "write key pair")
+	}
+
+	log.Debugf("Removing old public key from instance %s", options.MachineID)
+	err = updateSSHKeys(ctx, client, options.MachineID, func(keys []string) []string {
+		return removeSSHKey(keys, sshKeyEntry(oldPublicKey))
+	})
+	if err != nil {
+		return errors.Wrap(err, "remove old public key")
+	}
+
+	log.Infof("Rotated ssh key of %s", options.MachineID)
+	return nil
+}
+
+// verifySSHKey retries to connect with the given key until the guest agent picked it up
+func verifySSHKey(ctx context.Context, client *gcloud.Client, options *options.Options, privateKey []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// only offer the new key, otherwise an agent key could be accepted instead
+	verifyOptions := *options
+	verifyOptions.SSHAgent = false
+
+	for {
+		sshClient, err := newSSHClientWithKey(ctx, client, &verifyOptions, privateKey)
+		if err == nil {
+			_ = sshClient.Close()
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("instance didn't accept the new key within %s: %w", timeout, err)
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// updateSSHKeys changes the ssh-keys metadata of the instance line by line
+func updateSSHKeys(ctx context.Context, client *gcloud.Client, name string, update func(keys []string) []string) error {
+	return client.UpdateMetadata(ctx, name, func(items []*computepb.Items) []*computepb.Items {
+		for _, item := range items {
+			if item.GetKey() == "ssh-keys" {
+				keys := update(splitSSHKeys(item.GetValue()))
+				item.Value = ptr.Ptr(strings.Join(keys, "\n"))
+				return items
+			}
+		}
+
+		return append(items, &computepb.Items{
+			Key:   ptr.Ptr("ssh-keys"),
+			Value: ptr.Ptr(strings.Join(update(nil), "\n")),
+		})
+	})
+}
+
+func splitSSHKeys(value string) []string {
+	keys := []string{}
+	for _, line := range strings.Split(value, "\n") {
+		if strings.TrimSpace(line) != "" {
+			keys = append(keys, line)
+		}
+	}
+
+	return keys
+}
+
+func removeSSHKey(keys []string, key string) []string {
+	retKeys := []string{}
+	for _, k := range keys {
+		if strings.TrimSpace(k) != key {
+			retKeys = append(retKeys, k)
+		}
+	}
+
+	return retKeys
+}
+
+func sshKeyEntry(publicKey []byte) string {
+	return "devpod:" + strings.TrimSpace(string(publicKey))
+}
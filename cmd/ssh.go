@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/shell"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ssh"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+// SSHCmd holds the cmd flags
+type SSHCmd struct {
+	Forwards []string
+}
+
+// NewSSHCmd defines a command
+func NewSSHCmd() *cobra.Command {
+	cmd := &SSHCmd{}
+	sshCmd := &cobra.Command{
+		Use:   "ssh [-- command]",
+		Short: "Open an interactive shell on the instance",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(true)
+			if err != nil {
+				return err
+			}
+
+			// quote every argument, so they arrive on the instance as they were passed
+			quoted := make([]string, 0, len(args))
+			for _, arg := range args {
+				quoted = append(quoted, shell.Quote(arg))
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, strings.Join(quoted, " "), log.Default)
+		},
+	}
+	sshCmd.Flags().StringArrayVar(&cmd.Forwards, "forward", nil, "Forward a port while the shell is open, [L:|R:][bind_address:]port:host:hostport or D:[bind_address:]port")
+
+	return sshCmd
+}
+
+// Run runs the command logic
+func (cmd *SSHCmd) Run(ctx context.Context, options *options.Options, command string, log log.Logger) error {
+	forwards, err := parseForwards(cmd.Forwards)
+	if err != nil {
+		return err
+	}
+
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	instance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		return fmt.Errorf("instance %s doesn't exist", options.MachineID)
+	} else if instance.GetStatus() != "RUNNING" {
+		return fmt.Errorf("instance %s is %s, start it first", options.MachineID, strings.ToLower(instance.GetStatus()))
+	}
+
+	sshClient, err := newSSHClient(ctx, client, options)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	stopForwards, err := startForwards(sshClient, forwards, log)
+	if err != nil {
+		return err
+	}
+	defer stopForwards()
+
+	if options.SSHAgentForwarding {
+		err = ssh.ForwardAgent(sshClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Debugf("Connected to instance %s", options.MachineID)
+	return ssh.Shell(ctx, sshClient, command, options.SSHAgentForwarding)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/version"
+)
+
+// Keys of the labels and metadata that record how an instance was created
+const (
+	stampProviderVersion = "devpod-provider-version"
+	stampDevPodVersion   = "devpod-version"
+	stampCreator         = "devpod-creator"
+	stampSource          = "devpod-source"
+)
+
+var stampKeys = []string{stampProviderVersion, stampDevPodVersion, stampCreator, stampSource}
+
+var invalidLabelCharsRegEx = regexp.MustCompile("[^a-z0-9_-]+")
+
+// stampInstance records the provider and devpod version, the creator and the command that created
+// the instance. Metadata keeps the exact values, labels hold a sanitized copy to filter by.
+func stampInstance(ctx context.Context, client *gcloud.Client, instance *computepb.Instance, source string) {
+	creator, err := client.CallerEmail(ctx)
+	if err != nil || creator == "" {
+		creator = os.Getenv("USER")
+	}
+
+	values := map[string]string{
+		stampProviderVersion: version.Version,
+		stampDevPodVersion:   devPodVersion(),
+		stampCreator:         creator,
+		stampSource:          source,
+	}
+
+	if instance.Labels == nil {
+		instance.Labels = map[string]string{}
+	}
+	for _, key := range stampKeys {
+		instance.Labels[key] = labelValue(values[key])
+		instance.Metadata.Items = append(instance.Metadata.Items, &computepb.Items{
+			Key:   ptr.Ptr(key),
+			Value: ptr.Ptr(values[key]),
+		})
+	}
+}
+
+// devPodVersion returns the version of the devpod binary that runs the provider
+func devPodVersion() string {
+	v := version.DevPod()
+	if v == "" {
+		return "unknown"
+	}
+
+	return v
+}
+
+// labelValue turns the value into a valid label value
+func labelValue(value string) string {
+	value = invalidLabelCharsRegEx.ReplaceAllString(strings.ToLower(value), "_")
+	if len(value) > 63 {
+		value = value[:63]
+	}
+
+	return value
+}
+
+// instanceStamp returns the recorded creation info of the instance
+func instanceStamp(instance *computepb.Instance) map[string]string {
+	stamp := map[string]string{}
+	for _, item := range instance.GetMetadata().GetItems() {
+		for _, key := range stampKeys {
+			if item.GetKey() == key {
+				stamp[key] = item.GetValue()
+			}
+		}
+	}
+
+	return stamp
+}
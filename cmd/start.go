@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"time"
+
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
 	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -17,13 +21,13 @@ func NewStartCmd() *cobra.Command {
 	startCmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start an instance",
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
 			options, err := options.FromEnv(true)
 			if err != nil {
 				return err
 			}
 
-			return cmd.Run(context.Background(), options, log.Default)
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
 		},
 	}
 
@@ -31,12 +35,69 @@ func NewStartCmd() *cobra.Command {
 }
 
 // Run runs the command logic
-func (cmd *StartCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
-	client, err := gcloud.NewClient(ctx, options.Project, options.Zone)
+func (cmd *StartCmd) Run(ctx context.Context, options *options.Options, log log.Logger) (err error) {
+	defer observeInstanceReady("start", options, time.Now(), &err)
+
+	client, err := gcloud.NewClientFromOptions(ctx, options)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	return client.Start(ctx, options.MachineID)
+	if options.Spot && options.SpotAutoRecover {
+		recovered, err := recoverSpotInstance(ctx, client, options, log)
+		if err != nil {
+			return err
+		} else if recovered {
+			return waitForSSH(ctx, client, options, log)
+		}
+	}
+
+	err = client.Start(ctx, options.MachineID)
+	if err != nil {
+		return err
+	}
+
+	err = waitForSSH(ctx, client, options, log)
+	if err != nil {
+		return err
+	}
+
+	return rotateKeysIfExpired(ctx, client, options, log)
+}
+
+// recoverSpotInstance recreates a spot instance that was deleted on preemption from its retained boot disk
+func recoverSpotInstance(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) (bool, error) {
+	instance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return false, err
+	} else if instance != nil {
+		return false, nil
+	}
+
+	disk, err := client.GetDisk(ctx, options.MachineID)
+	if err != nil {
+		return false, err
+	} else if disk == nil {
+		return false, fmt.Errorf("instance %s doesn't exist and no retained boot disk was found, please recreate the machine", options.MachineID)
+	}
+
+	log.Infof("Instance %s was preempted, recreating it from its retained boot disk", options.MachineID)
+	recoverOptions := fromRetainedDisk(options, disk)
+	err = checkOrgPolicies(ctx, client, recoverOptions, log)
+	if err != nil {
+		return false, err
+	}
+	instance, err = buildInstance(recoverOptions)
+	if err != nil {
+		return false, err
+	}
+	stampInstance(ctx, client, instance, "spot-recover")
+
+	err = createInstance(ctx, client, instance, recoverOptions, log)
+	if err != nil {
+		return false, errors.Wrap(err, "recreate instance")
+	}
+
+	return true, nil
 }
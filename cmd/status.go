@@ -1,17 +1,38 @@
 package cmd
 
 import (
+	"cloud.google.com/go/compute/apiv1/computepb"
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/startup"
+	client2 "github.com/loft-sh/devpod/pkg/client"
 	"github.com/loft-sh/devpod/pkg/log"
 	"github.com/spf13/cobra"
 	"os"
+	"path"
+	"strings"
 )
 
 // StatusCmd holds the cmd flags
-type StatusCmd struct{}
+type StatusCmd struct {
+	Output string
+}
+
+// statusOutput is the status printed with --output json
+type statusOutput struct {
+	State           client2.Status `json:"state"`
+	Name            string         `json:"name"`
+	Zone            string         `json:"zone"`
+	MachineType     string         `json:"machineType,omitempty"`
+	Phase           string         `json:"phase,omitempty"`
+	ProviderVersion string         `json:"providerVersion,omitempty"`
+	DevPodVersion   string         `json:"devpodVersion,omitempty"`
+	Creator         string         `json:"creator,omitempty"`
+	Source          string         `json:"source,omitempty"`
+}
 
 // NewStatusCmd defines a command
 func NewStatusCmd() *cobra.Command {
@@ -19,32 +40,105 @@ func NewStatusCmd() *cobra.Command {
 	statusCmd := &cobra.Command{
 		Use:   "status",
 		Short: "Retrieve the status of an instance",
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
 			options, err := options.FromEnv(true)
 			if err != nil {
 				return err
 			}
 
-			return cmd.Run(context.Background(), options, log.Default)
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
 		},
 	}
 
+	statusCmd.Flags().StringVar(&cmd.Output, "output", "plain", "The output format, plain or json")
+
 	return statusCmd
 }
 
 // Run runs the command logic
 func (cmd *StatusCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
-	client, err := gcloud.NewClient(ctx, options.Project, options.Zone)
+	client, err := gcloud.NewClientFromOptions(ctx, options)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	status, err := client.Status(ctx, options.MachineID)
+	// a single get serves the status, the spot checks and the json output
+	instance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	}
+
+	status, err := gcloud.InstanceStatus(instance)
 	if err != nil {
 		return err
 	}
 
+	if options.Spot {
+		status, err = spotStatus(ctx, client, options, instance, status, log)
+		if err != nil {
+			return err
+		}
+	}
+
+	// report provisioning progress on stderr, stdout is reserved for the status
+	phase := ""
+	if status == client2.StatusRunning {
+		phase, err = client.GetGuestAttribute(ctx, options.MachineID, startup.PhaseKey)
+		if err == nil && strings.HasPrefix(phase, startup.PhaseErrorPrefix) {
+			log.ErrorStreamOnly().Warnf("Provisioning of instance %s failed: %s", options.MachineID, strings.TrimPrefix(phase, startup.PhaseErrorPrefix))
+		} else if phase != "" && phase != startup.PhaseDone {
+			log.ErrorStreamOnly().Infof("Instance %s is still provisioning, current phase: %s", options.MachineID, phase)
+		}
+	}
+
+	if cmd.Output == "json" {
+		return printStatusJSON(instance, options, status, phase)
+	}
+
 	_, err = fmt.Fprint(os.Stdout, status)
 	return err
 }
+
+func printStatusJSON(instance *computepb.Instance, options *options.Options, status client2.Status, phase string) error {
+	out := &statusOutput{
+		State: status,
+		Name:  options.MachineID,
+		Zone:  options.Zone,
+		Phase: phase,
+	}
+
+	if instance != nil {
+		stamp := instanceStamp(instance)
+		out.MachineType = path.Base(instance.GetMachineType())
+		out.ProviderVersion = stamp[stampProviderVersion]
+		out.DevPodVersion = stamp[stampDevPodVersion]
+		out.Creator = stamp[stampCreator]
+		out.Source = stamp[stampSource]
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// spotStatus reports preemptions and lets devpod start a deleted spot instance that can be recovered
+// from its retained boot disk
+func spotStatus(ctx context.Context, client *gcloud.Client, options *options.Options, instance *computepb.Instance, status client2.Status, log log.Logger) (client2.Status, error) {
+	if status == client2.StatusStopped {
+		preempted, err := client.WasPreempted(ctx, instance)
+		if err == nil && preempted {
+			log.ErrorStreamOnly().Warnf("Spot instance %s was preempted", options.MachineID)
+		}
+	} else if status == client2.StatusNotFound && options.SpotAutoRecover {
+		disk, err := client.GetDisk(ctx, options.MachineID)
+		if err != nil {
+			return status, err
+		} else if disk != nil {
+			log.ErrorStreamOnly().Warnf("Spot instance %s was preempted and deleted, it will be recreated from its boot disk on start", options.MachineID)
+			return client2.StatusStopped, nil
+		}
+	}
+
+	return status, nil
+}
@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
 	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ssh"
 	"github.com/loft-sh/devpod/pkg/log"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"io"
 	"net/http"
 	"os"
+	"os/exec"
+	"time"
 )
 
 // StopCmd holds the cmd flags
@@ -25,13 +28,13 @@ func NewStopCmd() *cobra.Command {
 	stopCmd := &cobra.Command{
 		Use:   "stop",
 		Short: "Stop an instance",
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
 			options, err := options.FromEnv(true)
 			if err != nil {
 				return err
 			}
 
-			return cmd.Run(context.Background(), options, log.Default)
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
 		},
 	}
 
@@ -42,16 +45,106 @@ func NewStopCmd() *cobra.Command {
 // Run runs the command logic
 func (cmd *StopCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
 	if cmd.Raw {
+		// raw stops are sent from the instance itself, so the hook can run locally
+		if options.PreStopCommand != "" {
+			err := runLocalPreStop(ctx, options, log)
+			if err != nil {
+				log.Warnf("Error running pre-stop command: %v", err)
+			}
+		}
+
 		return rawStop(ctx, options)
 	}
 
-	client, err := gcloud.NewClient(ctx, options.Project, options.Zone)
+	client, err := gcloud.NewClientFromOptions(ctx, options)
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	return client.Stop(ctx, options.MachineID, true)
+	if options.PreStopCommand != "" {
+		err = runPreStop(ctx, client, options, log)
+		if err != nil {
+			log.Warnf("Error running pre-stop command: %v", err)
+		}
+	}
+
+	if options.StopGracePeriod > 0 {
+		stopped, err := gracefulStop(ctx, client, options, log)
+		if err != nil {
+			log.Warnf("Error shutting down instance gracefully: %v", err)
+		} else if stopped {
+			return nil
+		}
+
+		log.Infof("Instance didn't shut down within %s, forcing stop", options.StopGracePeriod)
+	}
+
+	return client.Stop(ctx, options.MachineID, options.LocalSSDCount > 0, true)
+}
+
+// runPreStop runs the pre-stop command on the instance, e.g. to stop containers cleanly
+func runPreStop(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, preStopTimeout(options))
+	defer cancel()
+
+	sshClient, err := newSSHClient(ctx, client, options)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	log.Infof("Running pre-stop command")
+	return ssh.Run(ctx, sshClient, options.PreStopCommand, nil, os.Stderr, os.Stderr)
+}
+
+func runLocalPreStop(ctx context.Context, options *options.Options, log log.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, preStopTimeout(options))
+	defer cancel()
+
+	log.Infof("Running pre-stop command")
+	preStopCmd := exec.CommandContext(ctx, "sh", "-c", options.PreStopCommand)
+	preStopCmd.Stdout = os.Stderr
+	preStopCmd.Stderr = os.Stderr
+	return preStopCmd.Run()
+}
+
+func preStopTimeout(options *options.Options) time.Duration {
+	if options.StopGracePeriod > 0 {
+		return options.StopGracePeriod
+	}
+
+	return 2 * time.Minute
+}
+
+// gracefulStop shuts down the guest os, which stops docker cleanly, and waits until the instance
+// is terminated or the grace period is over
+func gracefulStop(ctx context.Context, client *gcloud.Client, options *options.Options, log log.Logger) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, options.StopGracePeriod)
+	defer cancel()
+
+	sshClient, err := newSSHClient(ctx, client, options)
+	if err != nil {
+		return false, err
+	}
+
+	log.Infof("Shutting down instance")
+	// the connection drops while shutting down, so the result can be ignored
+	_ = ssh.Run(ctx, sshClient, "sudo -n shutdown -h now", nil, io.Discard, io.Discard)
+	_ = sshClient.Close()
+
+	for {
+		instance, err := client.Get(ctx, options.MachineID)
+		if err == nil && (instance == nil || instance.GetStatus() == "TERMINATED") {
+			return true, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-time.After(5 * time.Second):
+		}
+	}
 }
 
 func rawStop(ctx context.Context, options *options.Options) error {
@@ -65,7 +158,12 @@ func rawStop(ctx context.Context, options *options.Options) error {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("https://compute.googleapis.com/compute/v1/projects/%s/zones/%s/instances/%s/stop", options.Project, options.Zone, options.MachineID), nil)
+	url := fmt.Sprintf("%s/compute/v1/projects/%s/zones/%s/instances/%s/stop", gcloud.NormalizeEndpoint(options.APIEndpoint), options.Project, options.Zone, options.MachineID)
+	if options.LocalSSDCount > 0 {
+		url += "?discardLocalSsd=true"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
 		return err
 	}
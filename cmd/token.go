@@ -16,8 +16,8 @@ func NewTokenCmd() *cobra.Command {
 	tokenCmd := &cobra.Command{
 		Use:   "token",
 		Short: "Prints an access token",
-		RunE: func(_ *cobra.Command, args []string) error {
-			return cmd.Run(context.Background())
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			return cmd.Run(cobraCmd.Context())
 		},
 	}
 
@@ -0,0 +1,420 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/startup"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// UpdateCmd holds the cmd flags
+type UpdateCmd struct{}
+
+// NewUpdateCmd defines a command
+func NewUpdateCmd() *cobra.Command {
+	cmd := &UpdateCmd{}
+	updateCmd := &cobra.Command{
+		Use:   "update",
+		Short: "Apply changed options to an existing instance",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(true)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
+		},
+	}
+
+	return updateCmd
+}
+
+// Run runs the command logic
+func (cmd *UpdateCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	err = checkOrgPolicies(ctx, client, options, log)
+	if err != nil {
+		return err
+	}
+
+	desired, err := buildInstance(options)
+	if err != nil {
+		return err
+	}
+
+	existing, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return err
+	} else if existing == nil {
+		return fmt.Errorf("instance %s doesn't exist", options.MachineID)
+	}
+
+	// labels, network tags and metadata can always be changed in place
+	err = reconcileInstance(ctx, client, existing, desired, log)
+	if err != nil {
+		return err
+	}
+
+	u := &updater{
+		stopped: existing.GetStatus() == "TERMINATED",
+		log:     log,
+	}
+
+	err = updateBootDisk(ctx, client, existing, desired, options, u)
+	if err != nil {
+		return err
+	}
+
+	err = updateAttachedDisks(ctx, client, existing, desired, u)
+	if err != nil {
+		return err
+	}
+
+	if path.Base(existing.GetMachineType()) != options.MachineType {
+		err = u.requireStop(fmt.Sprintf("machine type %s -> %s", path.Base(existing.GetMachineType()), options.MachineType), func() error {
+			return client.SetMachineType(ctx, existing.GetName(), desired.GetMachineType())
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if acceleratorsString(existing.GetGuestAccelerators()) != acceleratorsString(desired.GetGuestAccelerators()) {
+		err = u.requireStop(fmt.Sprintf("accelerators %s -> %s", acceleratorsString(existing.GetGuestAccelerators()), acceleratorsString(desired.GetGuestAccelerators())), func() error {
+			return client.SetAccelerators(ctx, existing.GetName(), desired.GetGuestAccelerators())
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !sameServiceAccount(existing.GetServiceAccounts(), desired.GetServiceAccounts()) {
+		var serviceAccount *computepb.ServiceAccount
+		if len(desired.GetServiceAccounts()) > 0 {
+			serviceAccount = desired.GetServiceAccounts()[0]
+		}
+
+		err = u.requireStop(fmt.Sprintf("service account %q -> %q", serviceAccountEmail(existing.GetServiceAccounts()), serviceAccountEmail(desired.GetServiceAccounts())), func() error {
+			return client.SetServiceAccount(ctx, existing.GetName(), serviceAccount)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// instances without SHIELDED_VM keep the shielded vm defaults of their image
+	if options.ShieldedVM && !existing.GetShieldedInstanceConfig().GetEnableSecureBoot() {
+		err = u.requireStop("shielded vm false -> true", func() error {
+			return client.SetShieldedInstanceConfig(ctx, existing.GetName(), desired.GetShieldedInstanceConfig())
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = updateScheduling(ctx, client, existing, desired, u)
+	if err != nil {
+		return err
+	}
+
+	if resourcePoliciesString(existing.GetResourcePolicies()) != resourcePoliciesString(desired.GetResourcePolicies()) {
+		err = u.requireStop(fmt.Sprintf("placement policy %s -> %s", resourcePoliciesString(existing.GetResourcePolicies()), resourcePoliciesString(desired.GetResourcePolicies())), func() error {
+			err := ensurePlacementPolicy(ctx, client, options)
+			if err != nil {
+				return err
+			}
+
+			return client.SetResourcePolicies(ctx, existing.GetName(), existing.GetResourcePolicies(), desired.GetResourcePolicies())
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(existing.GetNetworkInterfaces()) > 0 && len(desired.GetNetworkInterfaces()) > 0 {
+		existingNetwork := existing.GetNetworkInterfaces()[0]
+		desiredNetwork := desired.GetNetworkInterfaces()[0]
+		if desiredNetwork.Network != nil && !strings.HasSuffix(existingNetwork.GetNetwork(), desiredNetwork.GetNetwork()) {
+			u.requireRecreate(fmt.Sprintf("network %s -> %s", path.Base(existingNetwork.GetNetwork()), path.Base(desiredNetwork.GetNetwork())))
+		}
+		if desiredNetwork.Subnetwork != nil && !strings.HasSuffix(existingNetwork.GetSubnetwork(), desiredNetwork.GetSubnetwork()) {
+			u.requireRecreate(fmt.Sprintf("subnetwork %s -> %s", path.Base(existingNetwork.GetSubnetwork()), path.Base(desiredNetwork.GetSubnetwork())))
+		}
+		if (len(existingNetwork.GetAccessConfigs()) == 0) != options.NoPublicIP {
+			u.requireRecreate(fmt.Sprintf("public ip %t -> %t", len(existingNetwork.GetAccessConfigs()) > 0, !options.NoPublicIP))
+		}
+	}
+	if existing.GetHostname() != desired.GetHostname() {
+		u.requireRecreate(fmt.Sprintf("hostname %q -> %q", existing.GetHostname(), desired.GetHostname()))
+	}
+	if len(existing.GetNetworkInterfaces()) != len(desired.GetNetworkInterfaces()) {
+		u.requireRecreate(fmt.Sprintf("network interfaces %d -> %d", len(existing.GetNetworkInterfaces()), len(desired.GetNetworkInterfaces())))
+	}
+
+	return u.report(options.MachineID)
+}
+
+// updateBootDisk grows the boot disk in place and reports changes that need a new disk
+func updateBootDisk(ctx context.Context, client *gcloud.Client, existing, desired *computepb.Instance, options *options.Options, u *updater) error {
+	if len(existing.GetDisks()) == 0 {
+		return nil
+	}
+
+	diskSize, err := strconv.ParseInt(options.DiskSize, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "parse disk size")
+	}
+
+	bootDisk := existing.GetDisks()[0]
+	autoDelete := desired.GetDisks()[0].GetAutoDelete()
+	if bootDisk.GetAutoDelete() != autoDelete {
+		err = u.apply(fmt.Sprintf("boot disk auto delete %t -> %t", bootDisk.GetAutoDelete(), autoDelete), func() error {
+			return client.SetDiskAutoDelete(ctx, existing.GetName(), bootDisk.GetDeviceName(), autoDelete)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if diskSize > bootDisk.GetDiskSizeGb() {
+		err = u.apply(fmt.Sprintf("disk size %dGB -> %dGB", bootDisk.GetDiskSizeGb(), diskSize), func() error {
+			return client.ResizeDisk(ctx, path.Base(bootDisk.GetSource()), diskSize)
+		})
+		if err != nil {
+			return err
+		}
+	} else if diskSize < bootDisk.GetDiskSizeGb() {
+		u.requireRecreate(fmt.Sprintf("disk size %dGB -> %dGB, disks can't be shrunk", bootDisk.GetDiskSizeGb(), diskSize))
+	}
+
+	disk, err := client.GetDisk(ctx, path.Base(bootDisk.GetSource()))
+	if err != nil {
+		return err
+	} else if disk != nil && path.Base(disk.GetType()) != options.DiskType {
+		u.requireRecreate(fmt.Sprintf("disk type %s -> %s", path.Base(disk.GetType()), options.DiskType))
+	}
+
+	return nil
+}
+
+// updateAttachedDisks grows or attaches the data disk in place. Local ssds can only be added when
+// the instance is created.
+func updateAttachedDisks(ctx context.Context, client *gcloud.Client, existing, desired *computepb.Instance, u *updater) error {
+	existingSSDs, desiredSSDs := localSSDCount(existing), localSSDCount(desired)
+	if existingSSDs != desiredSSDs {
+		u.requireRecreate(fmt.Sprintf("local ssds %d -> %d", existingSSDs, desiredSSDs))
+	}
+
+	existingDisk, desiredDisk := attachedDisk(existing, startup.DataDiskDeviceName), attachedDisk(desired, startup.DataDiskDeviceName)
+	if desiredDisk == nil {
+		if existingDisk != nil {
+			u.requireRecreate("data disk removed")
+		}
+
+		return nil
+	} else if existingDisk == nil {
+		// the startup script mounts it on the next boot
+		return u.apply(fmt.Sprintf("data disk none -> %dGB", desiredDisk.GetInitializeParams().GetDiskSizeGb()), func() error {
+			return client.AttachDisk(ctx, existing.GetName(), desiredDisk)
+		})
+	}
+
+	if existingDisk.GetAutoDelete() != desiredDisk.GetAutoDelete() {
+		err := u.apply(fmt.Sprintf("data disk auto delete %t -> %t", existingDisk.GetAutoDelete(), desiredDisk.GetAutoDelete()), func() error {
+			return client.SetDiskAutoDelete(ctx, existing.GetName(), existingDisk.GetDeviceName(), desiredDisk.GetAutoDelete())
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	diskSize := desiredDisk.GetInitializeParams().GetDiskSizeGb()
+	if diskSize > existingDisk.GetDiskSizeGb() {
+		// the startup script grows the filesystem on the next boot
+		return u.apply(fmt.Sprintf("data disk size %dGB -> %dGB", existingDisk.GetDiskSizeGb(), diskSize), func() error {
+			return client.ResizeDisk(ctx, path.Base(existingDisk.GetSource()), diskSize)
+		})
+	} else if diskSize < existingDisk.GetDiskSizeGb() {
+		u.requireRecreate(fmt.Sprintf("data disk size %dGB -> %dGB, disks can't be shrunk", existingDisk.GetDiskSizeGb(), diskSize))
+	}
+
+	return nil
+}
+
+func attachedDisk(instance *computepb.Instance, deviceName string) *computepb.AttachedDisk {
+	for _, disk := range instance.GetDisks() {
+		if disk.GetDeviceName() == deviceName {
+			return disk
+		}
+	}
+
+	return nil
+}
+
+func localSSDCount(instance *computepb.Instance) int {
+	count := 0
+	for _, disk := range instance.GetDisks() {
+		if disk.GetType() == "SCRATCH" {
+			count++
+		}
+	}
+
+	return count
+}
+
+// updateScheduling applies maintenance changes in place, switching between spot and standard needs a stop
+func updateScheduling(ctx context.Context, client *gcloud.Client, existing, desired *computepb.Instance, u *updater) error {
+	existingScheduling := schedulingString(existing.GetScheduling())
+	desiredScheduling := schedulingString(desired.GetScheduling())
+	if existingScheduling == desiredScheduling {
+		return nil
+	}
+
+	scheduling := desired.GetScheduling()
+	if scheduling == nil {
+		scheduling = &computepb.Scheduling{}
+	}
+
+	change := fmt.Sprintf("scheduling %s -> %s", existingScheduling, desiredScheduling)
+	apply := func() error {
+		return client.SetScheduling(ctx, existing.GetName(), scheduling)
+	}
+	if provisioningModel(existing.GetScheduling()) != provisioningModel(scheduling) {
+		return u.requireStop(change, apply)
+	}
+
+	return u.apply(change, apply)
+}
+
+// updater applies changes and collects the ones that can't be applied right now
+type updater struct {
+	stopped bool
+	log     log.Logger
+
+	requiresStop     []string
+	requiresRecreate []string
+}
+
+func (u *updater) apply(change string, apply func() error) error {
+	u.log.Infof("Updating %s", change)
+	err := apply()
+	if err != nil {
+		return errors.Wrapf(err, "update %s", change)
+	}
+
+	return nil
+}
+
+func (u *updater) requireStop(change string, apply func() error) error {
+	if !u.stopped {
+		u.requiresStop = append(u.requiresStop, change)
+		return nil
+	}
+
+	return u.apply(change, apply)
+}
+
+func (u *updater) requireRecreate(change string) {
+	u.requiresRecreate = append(u.requiresRecreate, change)
+}
+
+func (u *updater) report(name string) error {
+	for _, change := range u.requiresStop {
+		u.log.Warnf("Stop the machine and rerun update to apply: %s", change)
+	}
+	for _, change := range u.requiresRecreate {
+		u.log.Warnf("Recreate the machine to apply: %s", change)
+	}
+
+	if len(u.requiresStop) > 0 || len(u.requiresRecreate) > 0 {
+		return fmt.Errorf("%d change(s) of instance %s couldn't be applied", len(u.requiresStop)+len(u.requiresRecreate), name)
+	}
+
+	return nil
+}
+
+func acceleratorsString(accelerators []*computepb.AcceleratorConfig) string {
+	if len(accelerators) == 0 {
+		return "none"
+	}
+
+	s := []string{}
+	for _, accelerator := range accelerators {
+		s = append(s, fmt.Sprintf("%dx %s", accelerator.GetAcceleratorCount(), path.Base(accelerator.GetAcceleratorType())))
+	}
+
+	return strings.Join(s, ", ")
+}
+
+func resourcePoliciesString(resourcePolicies []string) string {
+	if len(resourcePolicies) == 0 {
+		return "none"
+	}
+
+	s := []string{}
+	for _, resourcePolicy := range resourcePolicies {
+		s = append(s, path.Base(resourcePolicy))
+	}
+
+	return strings.Join(s, ", ")
+}
+
+func serviceAccountEmail(serviceAccounts []*computepb.ServiceAccount) string {
+	if len(serviceAccounts) == 0 {
+		return ""
+	}
+
+	return serviceAccounts[0].GetEmail()
+}
+
+// sameServiceAccount compares the service accounts, "default" matches the compute engine default service account
+func sameServiceAccount(existing, desired []*computepb.ServiceAccount) bool {
+	existingEmail := serviceAccountEmail(existing)
+	desiredEmail := serviceAccountEmail(desired)
+	if desiredEmail == "default" {
+		return strings.HasSuffix(existingEmail, "-compute@developer.gserviceaccount.com")
+	}
+
+	return existingEmail == desiredEmail
+}
+
+func provisioningModel(scheduling *computepb.Scheduling) string {
+	if scheduling.GetProvisioningModel() == "" {
+		return "STANDARD"
+	}
+
+	return scheduling.GetProvisioningModel()
+}
+
+// schedulingString fills in the defaults the api applies to unset fields
+func schedulingString(scheduling *computepb.Scheduling) string {
+	onHostMaintenance := scheduling.GetOnHostMaintenance()
+	if onHostMaintenance == "" {
+		onHostMaintenance = "MIGRATE"
+	}
+	automaticRestart := true
+	if scheduling != nil && scheduling.AutomaticRestart != nil {
+		automaticRestart = scheduling.GetAutomaticRestart()
+	}
+
+	s := fmt.Sprintf("%s/%s/restart=%t", provisioningModel(scheduling), onHostMaintenance, automaticRestart)
+	if scheduling.GetInstanceTerminationAction() != "" {
+		s += "/" + scheduling.GetInstanceTerminationAction()
+	}
+
+	return s
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/gcloud"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/lock"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+// WarmPoolCmd holds the cmd flags
+type WarmPoolCmd struct {
+	Timeout time.Duration
+}
+
+// NewWarmPoolCmd defines a command
+func NewWarmPoolCmd() *cobra.Command {
+	cmd := &WarmPoolCmd{}
+	warmPoolCmd := &cobra.Command{
+		Use:   "warm-pool",
+		Short: "Fill the warm pool with provisioned stopped instances",
+		RunE: func(cobraCmd *cobra.Command, args []string) error {
+			options, err := options.FromEnv(false)
+			if err != nil {
+				return err
+			}
+
+			return cmd.Run(cobraCmd.Context(), options, log.Default)
+		},
+	}
+
+	warmPoolCmd.Flags().DurationVar(&cmd.Timeout, "timeout", 20*time.Minute, "How long to wait for a pool instance to finish provisioning")
+	return warmPoolCmd
+}
+
+// Run runs the command logic
+func (cmd *WarmPoolCmd) Run(ctx context.Context, options *options.Options, log log.Logger) error {
+	if !usesWarmPool(options) {
+		return nil
+	}
+
+	client, err := gcloud.NewClientFromOptions(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// pool instances get their real ssh key once they are claimed
+	machineFolder, err := os.MkdirTemp("", "devpod-pool-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(machineFolder)
+
+	// only instances with the current spec count, pool instances of changed options are never claimed
+	instance, _, err := buildPoolInstance(options, machineFolder)
+	if err != nil {
+		return err
+	}
+	other, _, err := buildPoolInstance(options, machineFolder)
+	if err != nil {
+		return err
+	}
+	spec := poolSpec(instance)
+	if spec != poolSpec(other) {
+		log.Warnf("The instance depends on the machine id, e.g. through INSTANCE_HOSTNAME or a templated startup script, skipping the warm pool")
+		return nil
+	}
+
+	// every create starts a fill, only one of them counts and adds instances at a time
+	unlock, err := lock.File(poolLockFile(options), time.Second)
+	if err != nil {
+		log.Debugf("Skipping warm pool fill, another one is running: %v", err)
+		return nil
+	}
+	defer unlock()
+
+	for {
+		count, err := client.CountPool(ctx, spec)
+		if err != nil {
+			return err
+		} else if count >= options.WarmPoolSize {
+			return nil
+		}
+
+		err = cmd.addPoolInstance(ctx, client, options, machineFolder, log)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// poolLockFile returns the lock file that serializes filling the warm pool of the project and zone
+func poolLockFile(options *options.Options) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("devpod-gcloud-warm-pool-%s-%s.lock", options.Project, options.Zone))
+}
+
+func (cmd *WarmPoolCmd) addPoolInstance(ctx context.Context, client *gcloud.Client, options *options.Options, machineFolder string, log log.Logger) error {
+	instance, poolOptions, err := buildPoolInstance(options, machineFolder)
+	if err != nil {
+		return err
+	}
+	if instance.Labels == nil {
+		instance.Labels = map[string]string{}
+	}
+	instance.Labels[gcloud.PoolLabel] = gcloud.PoolStateAvailable
+	instance.Labels[gcloud.PoolSpecLabel] = poolSpec(instance)
+	stampInstance(ctx, client, instance, "warm-pool")
+
+	log.Infof("Creating pool instance %s", poolOptions.MachineID)
+	err = createInstance(ctx, client, instance, poolOptions, log)
+	if err != nil {
+		return errors.Wrap(err, "create pool instance")
+	}
+
+	err = waitForProvisioning(ctx, client, poolOptions.MachineID, cmd.Timeout)
+	if err != nil {
+		rollbackInstance(client, poolOptions.MachineID, "", log)
+		return err
+	}
+
+	return client.Stop(ctx, poolOptions.MachineID, poolOptions.LocalSSDCount > 0, false)
+}
+
+func buildPoolInstance(o *options.Options, machineFolder string) (*computepb.Instance, *options.Options, error) {
+	poolOptions := *o
+	poolOptions.MachineID = fmt.Sprintf("devpod-pool-%d", time.Now().UnixNano())
+	poolOptions.DevPodMachineID = poolOptions.MachineID
+	poolOptions.MachineFolder = machineFolder
+
+	instance, err := buildInstance(&poolOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return instance, &poolOptions, nil
+}
+
+// usesWarmPool returns true if machines are taken from the warm pool. Claimed instances keep the disk
+// names of the pool instance, so machines whose disks are looked up by name after the instance is gone
+// are always created from scratch.
+func usesWarmPool(options *options.Options) bool {
+	return options.WarmPoolSize > 0 && !retainBootDisk(options)
+}
+
+// claimPoolInstance takes over a pool instance that matches the desired instance and brings its labels,
+// network tags and metadata in line with it. Returns false if no instance could be claimed.
+func claimPoolInstance(ctx context.Context, client *gcloud.Client, options *options.Options, instance *computepb.Instance, log log.Logger) (bool, error) {
+	claimed, err := client.ClaimPool(ctx, options.MachineID, poolSpec(instance), instance.Metadata.Items)
+	if err != nil {
+		if claimed {
+			return true, errors.Wrap(err, "set up pool instance, run create again to finish it or delete the machine")
+		}
+
+		return false, errors.Wrap(err, "claim pool instance")
+	} else if !claimed {
+		return false, nil
+	}
+
+	log.Debugf("Claimed instance from warm pool")
+	claimedInstance, err := client.Get(ctx, options.MachineID)
+	if err != nil {
+		return true, err
+	} else if claimedInstance != nil {
+		err = reconcileInstance(ctx, client, claimedInstance, instance, log)
+		if err != nil {
+			return true, errors.Wrap(err, "update instance claimed from the warm pool")
+		}
+	}
+
+	return true, nil
+}
+
+// poolSpec returns a hash of everything that is fixed once a pool instance is provisioned. Name,
+// labels and metadata are set when the instance is claimed, the startup script already ran and is
+// part of the spec.
+func poolSpec(instance *computepb.Instance) string {
+	spec := proto.Clone(instance).(*computepb.Instance)
+	spec.Name = nil
+	spec.Labels = nil
+	spec.Metadata = nil
+	if startupScript := metadataItem(instance.GetMetadata().GetItems(), "startup-script"); startupScript != nil {
+		spec.Metadata = &computepb.Metadata{Items: []*computepb.Items{startupScript}}
+	}
+	for _, disk := range spec.Disks {
+		if disk.GetBoot() {
+			disk.DeviceName = nil
+		}
+		if disk.InitializeParams != nil {
+			disk.InitializeParams.DiskName = nil
+		}
+	}
+
+	out, err := proto.MarshalOptions{Deterministic: true}.Marshal(spec)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(out)
+	return hex.EncodeToString(sum[:8])
+}
+
+// replenishPool fills up the warm pool in a detached process, so the calling command can return immediately
+func replenishPool(log log.Logger) {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Debugf("Error replenishing warm pool: %v", err)
+		return
+	}
+
+	replenishCmd := exec.Command(executable, "warm-pool")
+	replenishCmd.Env = os.Environ()
+	err = replenishCmd.Start()
+	if err != nil {
+		log.Debugf("Error replenishing warm pool: %v", err)
+		return
+	}
+
+	_ = replenishCmd.Process.Release()
+}
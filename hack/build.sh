@@ -21,6 +21,9 @@ fi
 
 GO_BUILD_CMD="go build"
 GO_BUILD_LDFLAGS="-s -w"
+if [[ -n "${RELEASE_VERSION}" ]]; then
+    GO_BUILD_LDFLAGS="${GO_BUILD_LDFLAGS} -X github.com/loft-sh/devpod-provider-gcloud/pkg/version.Version=${RELEASE_VERSION}"
+fi
 
 if [[ -z "${PROVIDER_BUILD_PLATFORMS}" ]]; then
     PROVIDER_BUILD_PLATFORMS="linux windows darwin"
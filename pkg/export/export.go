@@ -0,0 +1,78 @@
+package export
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// Resources are the cloud resources that make up a machine
+type Resources struct {
+	Project string
+	Zone    string
+
+	Instance  *computepb.Instance
+	Disks     []*computepb.Disk
+	Firewalls []*computepb.Firewall
+	Addresses []*computepb.Address
+}
+
+// skippedMetadata are metadata keys that are specific to the machine and not exported
+var skippedMetadata = map[string]bool{
+	"ssh-keys": true,
+}
+
+// resourceName turns a gcloud resource name into a valid terraform identifier
+func resourceName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// metadataItems returns the exported metadata sorted by key
+func metadataItems(instance *computepb.Instance) []*computepb.Items {
+	items := []*computepb.Items{}
+	for _, item := range instance.GetMetadata().GetItems() {
+		if !skippedMetadata[item.GetKey()] {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].GetKey() < items[j].GetKey()
+	})
+	return items
+}
+
+// sortedKeys returns the keys of the given map in order
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// bootDisk returns the boot disk of the instance and the remaining disks
+func bootDisk(resources *Resources) (*computepb.Disk, []*computepb.Disk) {
+	var boot *computepb.Disk
+	other := []*computepb.Disk{}
+	for _, disk := range resources.Disks {
+		isBoot := false
+		for _, attached := range resources.Instance.GetDisks() {
+			if attached.GetBoot() && path.Base(attached.GetSource()) == disk.GetName() {
+				isBoot = true
+			}
+		}
+
+		if isBoot {
+			boot = disk
+		} else {
+			other = append(other, disk)
+		}
+	}
+
+	return boot, other
+}
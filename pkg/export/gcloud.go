@@ -0,0 +1,190 @@
+package export
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/shell"
+)
+
+// Gcloud renders the resources as a shell script of gcloud commands
+func Gcloud(resources *Resources) string {
+	b := &strings.Builder{}
+	instance := resources.Instance
+	boot, disks := bootDisk(resources)
+
+	b.WriteString("#!/bin/sh\nset -e\n\n")
+	fmt.Fprintf(b, "PROJECT=%s\nZONE=%s\n\n", shell.Quote(resources.Project), shell.Quote(resources.Zone))
+
+	for _, disk := range resources.Disks {
+		args := []string{
+			"gcloud compute disks create " + shell.Quote(disk.GetName()),
+			"--project \"$PROJECT\" --zone \"$ZONE\"",
+			"--type " + shell.Quote(path.Base(disk.GetType())),
+			fmt.Sprintf("--size %dGB", disk.GetSizeGb()),
+		}
+		if disk.GetSourceImage() != "" {
+			args = append(args, "--image "+shell.Quote(trimAPIPrefix(disk.GetSourceImage())))
+		}
+		if disk.GetSourceSnapshot() != "" {
+			args = append(args, "--source-snapshot "+shell.Quote(trimAPIPrefix(disk.GetSourceSnapshot())))
+		}
+		if disk.GetProvisionedIops() > 0 {
+			args = append(args, fmt.Sprintf("--provisioned-iops %d", disk.GetProvisionedIops()))
+		}
+		if len(disk.GetLabels()) > 0 {
+			args = append(args, "--labels "+shell.Quote(labelList(disk.GetLabels())))
+		}
+		writeCommand(b, args)
+	}
+
+	for _, address := range resources.Addresses {
+		writeCommand(b, []string{
+			"gcloud compute addresses create " + shell.Quote(address.GetName()),
+			"--project \"$PROJECT\" --region " + shell.Quote(options.RegionOf(resources.Zone)),
+			"--network-tier " + shell.Quote(address.GetNetworkTier()),
+		})
+	}
+
+	for _, firewall := range resources.Firewalls {
+		args := []string{
+			"gcloud compute firewall-rules create " + shell.Quote(firewall.GetName()),
+			"--project \"$PROJECT\"",
+			"--network " + shell.Quote(trimAPIPrefix(firewall.GetNetwork())),
+			"--direction " + shell.Quote(firewall.GetDirection()),
+			fmt.Sprintf("--priority %d", firewall.GetPriority()),
+		}
+		rules := []string{}
+		for _, allowed := range firewall.GetAllowed() {
+			rules = append(rules, protocolPorts(allowed.GetIPProtocol(), allowed.GetPorts())...)
+		}
+		if len(rules) > 0 {
+			args = append(args, "--allow "+shell.Quote(strings.Join(rules, ",")))
+		}
+		rules = []string{}
+		for _, denied := range firewall.GetDenied() {
+			rules = append(rules, protocolPorts(denied.GetIPProtocol(), denied.GetPorts())...)
+		}
+		if len(rules) > 0 {
+			args = append(args, "--action DENY --rules "+shell.Quote(strings.Join(rules, ",")))
+		}
+		if len(firewall.GetSourceRanges()) > 0 {
+			args = append(args, "--source-ranges "+shell.Quote(strings.Join(firewall.GetSourceRanges(), ",")))
+		}
+		if len(firewall.GetTargetTags()) > 0 {
+			args = append(args, "--target-tags "+shell.Quote(strings.Join(firewall.GetTargetTags(), ",")))
+		}
+		if len(firewall.GetTargetServiceAccounts()) > 0 {
+			args = append(args, "--target-service-accounts "+shell.Quote(strings.Join(firewall.GetTargetServiceAccounts(), ",")))
+		}
+		writeCommand(b, args)
+	}
+
+	// metadata values can contain anything, so they are passed as files
+	items := metadataItems(instance)
+	if len(items) > 0 {
+		b.WriteString("METADATA_DIR=$(mktemp -d)\ntrap 'rm -rf \"$METADATA_DIR\"' EXIT\n\n")
+		for _, item := range items {
+			fmt.Fprintf(b, "cat > \"$METADATA_DIR/%s\" <<'DEVPOD_METADATA_EOF'\n%s\nDEVPOD_METADATA_EOF\n\n", item.GetKey(), strings.TrimSuffix(item.GetValue(), "\n"))
+		}
+	}
+
+	args := []string{
+		"gcloud compute instances create " + shell.Quote(instance.GetName()),
+		"--project \"$PROJECT\" --zone \"$ZONE\"",
+		"--machine-type " + shell.Quote(path.Base(instance.GetMachineType())),
+	}
+	if boot != nil {
+		args = append(args, "--disk "+shell.Quote("name="+boot.GetName()+",boot=yes"))
+	}
+	for _, disk := range disks {
+		args = append(args, "--disk "+shell.Quote("name="+disk.GetName()))
+	}
+	if len(instance.GetTags().GetItems()) > 0 {
+		args = append(args, "--tags "+shell.Quote(strings.Join(instance.GetTags().GetItems(), ",")))
+	}
+	if len(instance.GetLabels()) > 0 {
+		args = append(args, "--labels "+shell.Quote(labelList(instance.GetLabels())))
+	}
+	for _, networkInterface := range instance.GetNetworkInterfaces() {
+		nic := []string{
+			"network=" + trimAPIPrefix(networkInterface.GetNetwork()),
+			"subnet=" + trimAPIPrefix(networkInterface.GetSubnetwork()),
+		}
+		if networkInterface.GetStackType() != "" {
+			nic = append(nic, "stack-type="+networkInterface.GetStackType())
+		}
+		if networkInterface.GetNicType() != "" {
+			nic = append(nic, "nic-type="+networkInterface.GetNicType())
+		}
+		if len(networkInterface.GetAccessConfigs()) == 0 {
+			nic = append(nic, "no-address")
+		} else {
+			accessConfig := networkInterface.GetAccessConfigs()[0]
+			nic = append(nic, "network-tier="+accessConfig.GetNetworkTier())
+			for _, address := range resources.Addresses {
+				if address.GetAddress() == accessConfig.GetNatIP() {
+					nic = append(nic, "address="+address.GetName())
+				}
+			}
+		}
+		args = append(args, "--network-interface "+shell.Quote(strings.Join(nic, ",")))
+	}
+	for _, accelerator := range instance.GetGuestAccelerators() {
+		args = append(args, "--accelerator "+shell.Quote(fmt.Sprintf("type=%s,count=%d", path.Base(accelerator.GetAcceleratorType()), accelerator.GetAcceleratorCount())))
+	}
+	for _, serviceAccount := range instance.GetServiceAccounts() {
+		args = append(args, "--service-account "+shell.Quote(serviceAccount.GetEmail()), "--scopes "+shell.Quote(strings.Join(serviceAccount.GetScopes(), ",")))
+	}
+	if scheduling := instance.GetScheduling(); scheduling != nil {
+		if scheduling.GetProvisioningModel() == "SPOT" {
+			args = append(args, "--provisioning-model SPOT")
+			if scheduling.GetInstanceTerminationAction() != "" {
+				args = append(args, "--instance-termination-action "+shell.Quote(scheduling.GetInstanceTerminationAction()))
+			}
+		}
+		if !scheduling.GetAutomaticRestart() {
+			args = append(args, "--no-restart-on-failure")
+		}
+		args = append(args, "--maintenance-policy "+shell.Quote(scheduling.GetOnHostMaintenance()))
+	}
+	if len(items) > 0 {
+		files := []string{}
+		for _, item := range items {
+			files = append(files, item.GetKey()+"=$METADATA_DIR/"+item.GetKey())
+		}
+		args = append(args, "--metadata-from-file \""+strings.Join(files, ",")+"\"")
+	}
+	writeCommand(b, args)
+
+	return b.String()
+}
+
+func writeCommand(b *strings.Builder, args []string) {
+	b.WriteString(strings.Join(args, " \\\n  "))
+	b.WriteString("\n\n")
+}
+
+func protocolPorts(protocol string, ports []string) []string {
+	if len(ports) == 0 {
+		return []string{protocol}
+	}
+
+	rules := []string{}
+	for _, port := range ports {
+		rules = append(rules, protocol+":"+port)
+	}
+
+	return rules
+}
+
+func labelList(labels map[string]string) string {
+	list := []string{}
+	for _, k := range sortedKeys(labels) {
+		list = append(list, k+"="+labels[k])
+	}
+
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,182 @@
+package export
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+)
+
+// Terraform renders the resources as terraform hcl for the google provider
+func Terraform(resources *Resources) string {
+	b := &strings.Builder{}
+	instance := resources.Instance
+	boot, disks := bootDisk(resources)
+
+	for _, disk := range resources.Disks {
+		fmt.Fprintf(b, "resource \"google_compute_disk\" %s {\n", strconv.Quote(resourceName(disk.GetName())))
+		fmt.Fprintf(b, "  name = %s\n", quote(disk.GetName()))
+		fmt.Fprintf(b, "  zone = %s\n", quote(resources.Zone))
+		fmt.Fprintf(b, "  type = %s\n", quote(path.Base(disk.GetType())))
+		fmt.Fprintf(b, "  size = %d\n", disk.GetSizeGb())
+		if disk.GetSourceImage() != "" {
+			fmt.Fprintf(b, "  image = %s\n", quote(trimAPIPrefix(disk.GetSourceImage())))
+		}
+		if disk.GetSourceSnapshot() != "" {
+			fmt.Fprintf(b, "  snapshot = %s\n", quote(trimAPIPrefix(disk.GetSourceSnapshot())))
+		}
+		if disk.GetProvisionedIops() > 0 {
+			fmt.Fprintf(b, "  provisioned_iops = %d\n", disk.GetProvisionedIops())
+		}
+		writeLabels(b, disk.GetLabels())
+		b.WriteString("}\n\n")
+	}
+
+	for _, address := range resources.Addresses {
+		fmt.Fprintf(b, "resource \"google_compute_address\" %s {\n", strconv.Quote(resourceName(address.GetName())))
+		fmt.Fprintf(b, "  name = %s\n", quote(address.GetName()))
+		fmt.Fprintf(b, "  region = %s\n", quote(options.RegionOf(resources.Zone)))
+		fmt.Fprintf(b, "  address_type = %s\n", quote(address.GetAddressType()))
+		fmt.Fprintf(b, "  network_tier = %s\n", quote(address.GetNetworkTier()))
+		b.WriteString("}\n\n")
+	}
+
+	for _, firewall := range resources.Firewalls {
+		fmt.Fprintf(b, "resource \"google_compute_firewall\" %s {\n", strconv.Quote(resourceName(firewall.GetName())))
+		fmt.Fprintf(b, "  name = %s\n", quote(firewall.GetName()))
+		fmt.Fprintf(b, "  network = %s\n", quote(trimAPIPrefix(firewall.GetNetwork())))
+		fmt.Fprintf(b, "  direction = %s\n", quote(firewall.GetDirection()))
+		fmt.Fprintf(b, "  priority = %d\n", firewall.GetPriority())
+		for _, allowed := range firewall.GetAllowed() {
+			fmt.Fprintf(b, "  allow {\n    protocol = %s\n", quote(allowed.GetIPProtocol()))
+			if len(allowed.GetPorts()) > 0 {
+				fmt.Fprintf(b, "    ports = %s\n", quoteList(allowed.GetPorts()))
+			}
+			b.WriteString("  }\n")
+		}
+		for _, denied := range firewall.GetDenied() {
+			fmt.Fprintf(b, "  deny {\n    protocol = %s\n", quote(denied.GetIPProtocol()))
+			if len(denied.GetPorts()) > 0 {
+				fmt.Fprintf(b, "    ports = %s\n", quoteList(denied.GetPorts()))
+			}
+			b.WriteString("  }\n")
+		}
+		if len(firewall.GetSourceRanges()) > 0 {
+			fmt.Fprintf(b, "  source_ranges = %s\n", quoteList(firewall.GetSourceRanges()))
+		}
+		if len(firewall.GetTargetTags()) > 0 {
+			fmt.Fprintf(b, "  target_tags = %s\n", quoteList(firewall.GetTargetTags()))
+		}
+		if len(firewall.GetTargetServiceAccounts()) > 0 {
+			fmt.Fprintf(b, "  target_service_accounts = %s\n", quoteList(firewall.GetTargetServiceAccounts()))
+		}
+		b.WriteString("}\n\n")
+	}
+
+	fmt.Fprintf(b, "resource \"google_compute_instance\" %s {\n", strconv.Quote(resourceName(instance.GetName())))
+	fmt.Fprintf(b, "  name = %s\n", quote(instance.GetName()))
+	fmt.Fprintf(b, "  zone = %s\n", quote(resources.Zone))
+	fmt.Fprintf(b, "  machine_type = %s\n", quote(path.Base(instance.GetMachineType())))
+	if len(instance.GetTags().GetItems()) > 0 {
+		fmt.Fprintf(b, "  tags = %s\n", quoteList(instance.GetTags().GetItems()))
+	}
+	writeLabels(b, instance.GetLabels())
+
+	if boot != nil {
+		fmt.Fprintf(b, "\n  boot_disk {\n    source = google_compute_disk.%s.self_link\n  }\n", resourceName(boot.GetName()))
+	}
+	for _, disk := range disks {
+		fmt.Fprintf(b, "\n  attached_disk {\n    source = google_compute_disk.%s.self_link\n  }\n", resourceName(disk.GetName()))
+	}
+
+	for _, networkInterface := range instance.GetNetworkInterfaces() {
+		b.WriteString("\n  network_interface {\n")
+		fmt.Fprintf(b, "    network = %s\n", quote(trimAPIPrefix(networkInterface.GetNetwork())))
+		fmt.Fprintf(b, "    subnetwork = %s\n", quote(trimAPIPrefix(networkInterface.GetSubnetwork())))
+		if networkInterface.GetStackType() != "" {
+			fmt.Fprintf(b, "    stack_type = %s\n", quote(networkInterface.GetStackType()))
+		}
+		if networkInterface.GetNicType() != "" {
+			fmt.Fprintf(b, "    nic_type = %s\n", quote(networkInterface.GetNicType()))
+		}
+		for _, accessConfig := range networkInterface.GetAccessConfigs() {
+			b.WriteString("    access_config {\n")
+			for _, address := range resources.Addresses {
+				if address.GetAddress() == accessConfig.GetNatIP() {
+					fmt.Fprintf(b, "      nat_ip = google_compute_address.%s.address\n", resourceName(address.GetName()))
+				}
+			}
+			fmt.Fprintf(b, "      network_tier = %s\n", quote(accessConfig.GetNetworkTier()))
+			b.WriteString("    }\n")
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, accelerator := range instance.GetGuestAccelerators() {
+		fmt.Fprintf(b, "\n  guest_accelerator {\n    type = %s\n    count = %d\n  }\n", quote(path.Base(accelerator.GetAcceleratorType())), accelerator.GetAcceleratorCount())
+	}
+
+	for _, serviceAccount := range instance.GetServiceAccounts() {
+		fmt.Fprintf(b, "\n  service_account {\n    email = %s\n    scopes = %s\n  }\n", quote(serviceAccount.GetEmail()), quoteList(serviceAccount.GetScopes()))
+	}
+
+	if scheduling := instance.GetScheduling(); scheduling != nil {
+		b.WriteString("\n  scheduling {\n")
+		if scheduling.GetProvisioningModel() == "SPOT" {
+			b.WriteString("    preemptible = true\n")
+			fmt.Fprintf(b, "    provisioning_model = %s\n", quote(scheduling.GetProvisioningModel()))
+			if scheduling.GetInstanceTerminationAction() != "" {
+				fmt.Fprintf(b, "    instance_termination_action = %s\n", quote(scheduling.GetInstanceTerminationAction()))
+			}
+		}
+		fmt.Fprintf(b, "    automatic_restart = %t\n", scheduling.GetAutomaticRestart())
+		fmt.Fprintf(b, "    on_host_maintenance = %s\n", quote(scheduling.GetOnHostMaintenance()))
+		b.WriteString("  }\n")
+	}
+
+	items := metadataItems(instance)
+	if len(items) > 0 {
+		b.WriteString("\n  metadata = {\n")
+		for _, item := range items {
+			fmt.Fprintf(b, "    %s = %s\n", quote(item.GetKey()), quote(item.GetValue()))
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+func writeLabels(b *strings.Builder, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	b.WriteString("  labels = {\n")
+	for _, k := range sortedKeys(labels) {
+		fmt.Fprintf(b, "    %s = %s\n", quote(k), quote(labels[k]))
+	}
+	b.WriteString("  }\n")
+}
+
+// quote returns the value as hcl string literal, escaping template sequences
+func quote(value string) string {
+	value = strings.ReplaceAll(value, "${", "$${")
+	value = strings.ReplaceAll(value, "%{", "%%{")
+	return strconv.Quote(value)
+}
+
+func quoteList(values []string) string {
+	quoted := []string{}
+	for _, value := range values {
+		quoted = append(quoted, quote(value))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func trimAPIPrefix(url string) string {
+	return strings.TrimPrefix(url, "https://www.googleapis.com/compute/v1/")
+}
@@ -0,0 +1,40 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"google.golang.org/api/iterator"
+)
+
+// InstanceAddresses returns the reserved addresses in the zone's region that are used by the given instance
+func (c *Client) InstanceAddresses(ctx context.Context, instance *computepb.Instance) ([]*computepb.Address, error) {
+	region := options.RegionOf(c.Zone)
+	addresses := []*computepb.Address{}
+	it := c.AddressClient.List(ctx, &computepb.ListAddressesRequest{
+		Project: c.Project,
+		Region:  region,
+		Filter:  ptr.Ptr("status = IN_USE"),
+	})
+	for {
+		address, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("list addresses of region %s: %w", region, err)
+		}
+
+		for _, user := range address.GetUsers() {
+			if strings.HasSuffix(user, "/instances/"+instance.GetName()) {
+				addresses = append(addresses, address)
+				break
+			}
+		}
+	}
+
+	return addresses, nil
+}
@@ -0,0 +1,93 @@
+package gcloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+)
+
+// AuthError is returned if the credentials are missing, expired, revoked or need a reauthentication
+type AuthError struct {
+	Reason string
+	Err    error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%s, %s", e.Reason, LoginHint())
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// IsAuthError checks if the error chain contains an AuthError
+func IsAuthError(err error) bool {
+	authErr := &AuthError{}
+	return errors.As(err, &authErr)
+}
+
+// LoginHint tells the user how to renew the credentials the provider uses
+func LoginHint() string {
+	if os.Getenv("GCLOUD_JSON_AUTH") != "" {
+		return "please update GCLOUD_JSON_AUTH with a valid service account key"
+	} else if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" {
+		return "please check the credentials in GOOGLE_APPLICATION_CREDENTIALS"
+	}
+
+	return "please run gcloud auth application-default login"
+}
+
+// authTransport turns token refresh failures and rejected credentials into an AuthError, so they
+// aren't buried in the error of the api call or operation that happened to hit them
+type authTransport struct {
+	base http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, asAuthError(err)
+	} else if resp.StatusCode == http.StatusUnauthorized {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+		return nil, &AuthError{
+			Reason: "the credentials were rejected",
+			Err:    fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, string(body)),
+		}
+	}
+
+	return resp, nil
+}
+
+// asAuthError turns a failed token refresh into an AuthError and returns other errors as they are
+func asAuthError(err error) error {
+	retrieveErr := &oauth2.RetrieveError{}
+	if errors.As(err, &retrieveErr) {
+		return &AuthError{Reason: tokenErrorReason(retrieveErr), Err: err}
+	}
+
+	return err
+}
+
+// tokenErrorReason explains why the token couldn't be refreshed
+func tokenErrorReason(err *oauth2.RetrieveError) string {
+	body := &struct {
+		Error        string `json:"error"`
+		ErrorSubtype string `json:"error_subtype"`
+	}{}
+	_ = json.Unmarshal(err.Body, body)
+
+	switch {
+	case body.ErrorSubtype == "invalid_rapt":
+		return "your organization requires you to reauthenticate"
+	case body.Error == "invalid_grant":
+		return "the credentials expired or were revoked"
+	}
+
+	return "the access token couldn't be refreshed"
+}
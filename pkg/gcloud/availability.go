@@ -0,0 +1,118 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"google.golang.org/api/iterator"
+)
+
+// CheckMachineTypeAvailability verifies the machine type exists in the client's zone and lists
+// nearby zones that offer it otherwise
+func (c *Client) CheckMachineTypeAvailability(ctx context.Context, machineType string) error {
+	_, err := c.MachineTypeClient.Get(ctx, &computepb.GetMachineTypeRequest{
+		MachineType: machineType,
+		Project:     c.Project,
+		Zone:        c.Zone,
+	})
+	if err == nil {
+		return nil
+	} else if !isNotFound(err) {
+		return fmt.Errorf("get machine type %s: %w", machineType, err)
+	}
+
+	zones := []string{}
+	it := c.MachineTypeClient.AggregatedList(ctx, &computepb.AggregatedListMachineTypesRequest{
+		Filter:  ptr.Ptr(fmt.Sprintf("name=%s", machineType)),
+		Project: c.Project,
+	})
+	for {
+		pair, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return fmt.Errorf("list machine types: %w", err)
+		}
+
+		if len(pair.Value.GetMachineTypes()) > 0 {
+			zones = append(zones, strings.TrimPrefix(pair.Key, "zones/"))
+		}
+	}
+
+	return notAvailableError("machine type", machineType, c.Zone, zones)
+}
+
+// CheckAcceleratorAvailability verifies the accelerator type exists in the client's zone with at least the
+// given count per instance and lists nearby zones that offer it otherwise
+func (c *Client) CheckAcceleratorAvailability(ctx context.Context, acceleratorType string, count int) error {
+	accelerator, err := c.AcceleratorTypeClient.Get(ctx, &computepb.GetAcceleratorTypeRequest{
+		AcceleratorType: acceleratorType,
+		Project:         c.Project,
+		Zone:            c.Zone,
+	})
+	if err == nil {
+		if max := accelerator.GetMaximumCardsPerInstance(); max > 0 && int32(count) > max {
+			return fmt.Errorf("accelerator %s supports at most %d cards per instance, but %d were requested", acceleratorType, max, count)
+		}
+
+		return nil
+	} else if !isNotFound(err) {
+		return fmt.Errorf("get accelerator type %s: %w", acceleratorType, err)
+	}
+
+	zones := []string{}
+	it := c.AcceleratorTypeClient.AggregatedList(ctx, &computepb.AggregatedListAcceleratorTypesRequest{
+		Filter:  ptr.Ptr(fmt.Sprintf("name=%s", acceleratorType)),
+		Project: c.Project,
+	})
+	for {
+		pair, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return fmt.Errorf("list accelerator types: %w", err)
+		}
+
+		if len(pair.Value.GetAcceleratorTypes()) > 0 {
+			zones = append(zones, strings.TrimPrefix(pair.Key, "zones/"))
+		}
+	}
+
+	return notAvailableError("accelerator", acceleratorType, c.Zone, zones)
+}
+
+func notAvailableError(kind, name, zone string, zones []string) error {
+	if len(zones) == 0 {
+		return fmt.Errorf("%s %s doesn't exist in any zone, please check the name", kind, name)
+	}
+
+	// zones in the same region come first, then zones on the same continent
+	region := options.RegionOf(zone)
+	continent := zone[:strings.Index(zone, "-")]
+	score := func(z string) int {
+		if strings.HasPrefix(z, region+"-") {
+			return 0
+		} else if strings.HasPrefix(z, continent+"-") {
+			return 1
+		}
+
+		return 2
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		if score(zones[i]) != score(zones[j]) {
+			return score(zones[i]) < score(zones[j])
+		}
+
+		return zones[i] < zones[j]
+	})
+	if len(zones) > 10 {
+		zones = zones[:10]
+	}
+
+	return fmt.Errorf("%s %s is not available in zone %s, it is available in nearby zones: %s", kind, name, zone, strings.Join(zones, ", "))
+}
@@ -0,0 +1,123 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"google.golang.org/api/iterator"
+)
+
+// CreateDiskWithThroughput creates a disk from the given initialize params with a provisioned throughput
+// in MiB/s. The vendored sdk doesn't know the provisionedThroughput field yet, so the disk is created
+// with a raw request and has to be attached by its returned url afterwards.
+func (c *Client) CreateDiskWithThroughput(ctx context.Context, name string, params *computepb.AttachedDiskInitializeParams, throughput int64) (string, error) {
+	disk := map[string]interface{}{
+		"name":                  name,
+		"type":                  params.GetDiskType(),
+		"sizeGb":                fmt.Sprintf("%d", params.GetDiskSizeGb()),
+		"provisionedThroughput": fmt.Sprintf("%d", throughput),
+	}
+	if params.ProvisionedIops != nil {
+		disk["provisionedIops"] = fmt.Sprintf("%d", params.GetProvisionedIops())
+	}
+	if params.SourceImage != nil {
+		disk["sourceImage"] = params.GetSourceImage()
+	}
+	if params.SourceSnapshot != nil {
+		disk["sourceSnapshot"] = params.GetSourceSnapshot()
+	}
+
+	err := c.rawZoneOperation(ctx, http.MethodPost, fmt.Sprintf("/projects/%s/zones/%s/disks", c.Project, c.Zone), disk)
+	if err != nil {
+		return "", fmt.Errorf("create disk %s: %w", name, err)
+	}
+
+	return fmt.Sprintf("projects/%s/zones/%s/disks/%s", c.Project, c.Zone, name), nil
+}
+
+// GetDisk returns the disk with the given name in the client's zone or nil if it doesn't exist
+func (c *Client) GetDisk(ctx context.Context, name string) (*computepb.Disk, error) {
+	disk, err := c.DiskClient.Get(expectNotFound(ctx), &computepb.GetDiskRequest{
+		Disk:    name,
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return disk, nil
+}
+
+func (c *Client) DeleteDisk(ctx context.Context, name string) error {
+	operation, err := c.DiskClient.Delete(expectNotFound(ctx), &computepb.DeleteDiskRequest{
+		Disk:    name,
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// ResizeDisk grows the disk with the given name, disks can't be shrunk
+func (c *Client) ResizeDisk(ctx context.Context, name string, sizeGb int64) error {
+	operation, err := c.DiskClient.Resize(ctx, &computepb.ResizeDiskRequest{
+		Disk: name,
+		DisksResizeRequestResource: &computepb.DisksResizeRequest{
+			SizeGb: &sizeGb,
+		},
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// ListDisks returns the disks with the given names in the client's zone with a single filtered request
+func (c *Client) ListDisks(ctx context.Context, names []string) ([]*computepb.Disk, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	filters := []string{}
+	for _, name := range names {
+		filters = append(filters, fmt.Sprintf("(name = %q)", name))
+	}
+
+	disks := []*computepb.Disk{}
+	it := c.DiskClient.List(ctx, &computepb.ListDisksRequest{
+		Filter:  ptr.Ptr(strings.Join(filters, " OR ")),
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	for {
+		disk, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("list disks: %w", err)
+		}
+
+		disks = append(disks, disk)
+	}
+
+	return disks, nil
+}
@@ -0,0 +1,246 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"google.golang.org/api/iterator"
+)
+
+// ErrFirewallCheckSkipped is returned if the caller isn't allowed to list the firewall rules of the network
+var ErrFirewallCheckSkipped = fmt.Errorf("not allowed to list firewall rules, skipping firewall check")
+
+// SubnetworkNetwork returns the network url of the given subnetwork
+func (c *Client) SubnetworkNetwork(ctx context.Context, subnetwork string) (string, error) {
+	project, region, name, err := parseSubnetwork(subnetwork)
+	if err != nil {
+		return "", err
+	}
+
+	sn, err := c.SubnetworkClient.Get(ctx, &computepb.GetSubnetworkRequest{
+		Project:    project,
+		Region:     region,
+		Subnetwork: name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("get subnetwork %s: %w", subnetwork, err)
+	}
+
+	return sn.GetNetwork(), nil
+}
+
+// CheckSSHIngress verifies that a vpc firewall rule of the network allows ingress to tcp:22 for
+// instances with the given tags or service account. With publicSource the rule needs to allow a
+// public source range, otherwise any source is accepted. Hierarchical firewall policies are not
+// evaluated.
+func (c *Client) CheckSSHIngress(ctx context.Context, network string, tags []string, serviceAccount string, publicSource bool) error {
+	project, name, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	var allow, deny *computepb.Firewall
+	it := c.FirewallClient.List(ctx, &computepb.ListFirewallsRequest{
+		Project: project,
+		Filter:  ptr.Ptr(fmt.Sprintf(`(disabled = false) AND (network eq ".*/projects/%s/global/networks/%s")`, project, name)),
+	})
+	for {
+		firewall, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			if isForbidden(err) {
+				return ErrFirewallCheckSkipped
+			}
+
+			return fmt.Errorf("list firewall rules of project %s: %w", project, err)
+		}
+
+		if firewall.GetDisabled() || firewall.GetDirection() != "INGRESS" || !strings.HasSuffix(firewall.GetNetwork(), "projects/"+project+"/global/networks/"+name) {
+			continue
+		} else if !firewallTargets(firewall, tags, serviceAccount) {
+			continue
+		}
+
+		if allowsPort(firewall.GetAllowed(), 22) && (!publicSource || hasPublicSource(firewall.GetSourceRanges())) {
+			if allow == nil || firewall.GetPriority() < allow.GetPriority() {
+				allow = firewall
+			}
+		} else if deniesPort(firewall.GetDenied(), 22) && hasPublicSource(firewall.GetSourceRanges()) {
+			if deny == nil || firewall.GetPriority() < deny.GetPriority() {
+				deny = firewall
+			}
+		}
+	}
+
+	sourceRanges := "10.0.0.0/8"
+	if publicSource {
+		sourceRanges = "0.0.0.0/0"
+	}
+	targets := "instances with tags " + strings.Join(tags, ", ")
+	targetFlag := "--target-tags " + strings.Join(tags, ",")
+	if len(tags) == 0 {
+		targets = "all instances"
+		targetFlag = ""
+	}
+
+	if allow == nil {
+		return fmt.Errorf("no firewall rule on network %s allows ingress to tcp:22 from %s for %s, connections to the instance will time out. Create one with: gcloud compute firewall-rules create devpod-allow-ssh --project %s --network %s --direction INGRESS --allow tcp:22 --source-ranges %s %s", name, sourceDescription(publicSource), targets, project, name, sourceRanges, targetFlag)
+	} else if deny != nil && deny.GetPriority() <= allow.GetPriority() {
+		return fmt.Errorf("firewall rule %s (priority %d) on network %s denies ingress to tcp:22 before rule %s (priority %d) allows it, connections to the instance will time out", deny.GetName(), deny.GetPriority(), name, allow.GetName(), allow.GetPriority())
+	}
+
+	return nil
+}
+
+// InstanceFirewalls returns the enabled vpc firewall rules of the network that apply to the given instance
+func (c *Client) InstanceFirewalls(ctx context.Context, instance *computepb.Instance) ([]*computepb.Firewall, error) {
+	if len(instance.GetNetworkInterfaces()) == 0 {
+		return nil, nil
+	}
+
+	project, name, err := parseNetwork(instance.GetNetworkInterfaces()[0].GetNetwork())
+	if err != nil {
+		return nil, err
+	}
+
+	serviceAccount := ""
+	if len(instance.GetServiceAccounts()) > 0 {
+		serviceAccount = instance.GetServiceAccounts()[0].GetEmail()
+	}
+
+	firewalls := []*computepb.Firewall{}
+	it := c.FirewallClient.List(ctx, &computepb.ListFirewallsRequest{
+		Project: project,
+		Filter:  ptr.Ptr(fmt.Sprintf(`(disabled = false) AND (network eq ".*/projects/%s/global/networks/%s")`, project, name)),
+	})
+	for {
+		firewall, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("list firewall rules of project %s: %w", project, err)
+		}
+
+		if firewall.GetDisabled() || !strings.HasSuffix(firewall.GetNetwork(), "projects/"+project+"/global/networks/"+name) {
+			continue
+		} else if !firewallTargets(firewall, instance.GetTags().GetItems(), serviceAccount) {
+			continue
+		}
+
+		firewalls = append(firewalls, firewall)
+	}
+
+	return firewalls, nil
+}
+
+// parseNetwork splits projects/{{project}}/global/networks/{{name}}
+func parseNetwork(network string) (string, string, error) {
+	s := strings.Split(strings.TrimPrefix(network, "https://www.googleapis.com/compute/v1/"), "/")
+	if len(s) != 5 || s[0] != "projects" || s[2] != "global" || s[3] != "networks" {
+		return "", "", fmt.Errorf("unexpected network %s", network)
+	}
+
+	return s[1], s[4], nil
+}
+
+func firewallTargets(firewall *computepb.Firewall, tags []string, serviceAccount string) bool {
+	if len(firewall.GetTargetTags()) == 0 && len(firewall.GetTargetServiceAccounts()) == 0 {
+		return true
+	}
+
+	for _, targetTag := range firewall.GetTargetTags() {
+		for _, tag := range tags {
+			if targetTag == tag {
+				return true
+			}
+		}
+	}
+
+	for _, targetServiceAccount := range firewall.GetTargetServiceAccounts() {
+		if serviceAccount != "" && targetServiceAccount == serviceAccount {
+			return true
+		}
+	}
+
+	return false
+}
+
+func allowsPort(allowed []*computepb.Allowed, port int) bool {
+	for _, a := range allowed {
+		if matchesPort(a.GetIPProtocol(), a.GetPorts(), port) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func deniesPort(denied []*computepb.Denied, port int) bool {
+	for _, d := range denied {
+		if matchesPort(d.GetIPProtocol(), d.GetPorts(), port) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesPort(protocol string, ports []string, port int) bool {
+	if protocol != "tcp" && protocol != "all" {
+		return false
+	} else if len(ports) == 0 {
+		return true
+	}
+
+	for _, p := range ports {
+		from, to, found := strings.Cut(p, "-")
+		if !found {
+			to = from
+		}
+
+		fromPort, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		toPort, err := strconv.Atoi(to)
+		if err != nil {
+			continue
+		}
+
+		if fromPort <= port && port <= toPort {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasPublicSource returns true if any of the source ranges contains public addresses
+func hasPublicSource(sourceRanges []string) bool {
+	for _, sourceRange := range sourceRanges {
+		ip, _, err := net.ParseCIDR(sourceRange)
+		if err != nil {
+			ip = net.ParseIP(sourceRange)
+		}
+
+		if ip != nil && (ip.IsUnspecified() || !ip.IsPrivate()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sourceDescription(publicSource bool) string {
+	if publicSource {
+		return "the internet"
+	}
+
+	return "internal addresses"
+}
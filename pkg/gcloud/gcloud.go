@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +13,8 @@ import (
 	compute "cloud.google.com/go/compute/apiv1"
 	computepb "cloud.google.com/go/compute/apiv1/computepb"
 	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
 	"github.com/loft-sh/devpod/pkg/client"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -25,25 +29,169 @@ func NewClient(ctx context.Context, project, zone string, opts ...option.ClientO
 		return nil, err
 	}
 
-	instanceClient, err := compute.NewInstancesRESTClient(ctx, opts...)
+	// a single authenticated http client lets all api clients share the token and the http/2 connections
+	tokenSource, err := google.DefaultTokenSource(ctx, cloudPlatformScope)
+	if err != nil {
+		return nil, &AuthError{Reason: "no credentials found", Err: err}
+	}
+	httpClient := oauth2.NewClient(ctx, tokenSource)
+	httpClient.Transport = &authTransport{base: &metricsTransport{base: httpClient.Transport}}
+	opts = append([]option.ClientOption{option.WithHTTPClient(httpClient)}, opts...)
+
+	c := &Client{
+		Project:     project,
+		Zone:        zone,
+		Endpoint:    defaultEndpoint,
+		httpClient:  httpClient,
+		tokenSource: tokenSource,
+	}
+	// clients created before a failing one are closed again
+	created := []io.Closer{}
+	fail := func(err error) (*Client, error) {
+		_ = closeAll(created)
+		return nil, err
+	}
+
+	c.InstanceClient, err = compute.NewInstancesRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.InstanceClient)
+
+	c.ImageClient, err = compute.NewImagesRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.ImageClient)
+
+	c.OperationClient, err = compute.NewZoneOperationsRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.OperationClient)
+
+	c.DiskClient, err = compute.NewDisksRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.DiskClient)
+
+	c.FirewallClient, err = compute.NewFirewallsRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.FirewallClient)
+
+	c.AddressClient, err = compute.NewAddressesRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.AddressClient)
+
+	c.RouterClient, err = compute.NewRoutersRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.RouterClient)
+
+	c.SubnetworkClient, err = compute.NewSubnetworksRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.SubnetworkClient)
+
+	c.MachineImageClient, err = compute.NewMachineImagesRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.MachineImageClient)
+
+	c.SnapshotClient, err = compute.NewSnapshotsRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.SnapshotClient)
+
+	c.ProjectClient, err = compute.NewProjectsRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.ProjectClient)
+
+	c.ResourcePolicyClient, err = compute.NewResourcePoliciesRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.ResourcePolicyClient)
+
+	c.MachineTypeClient, err = compute.NewMachineTypesRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.MachineTypeClient)
+
+	c.AcceleratorTypeClient, err = compute.NewAcceleratorTypesRESTClient(ctx, opts...)
+	if err != nil {
+		return fail(err)
+	}
+	created = append(created, c.AcceleratorTypeClient)
+
+	return c, nil
+}
+
+// NewClientFromOptions creates a client for the project, zone and api endpoint in the given options
+func NewClientFromOptions(ctx context.Context, options *options.Options) (*Client, error) {
+	endpoint := NormalizeEndpoint(options.APIEndpoint)
+	client, err := NewClient(ctx, options.Project, options.Zone, option.WithEndpoint(endpoint))
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{
-		InstanceClient: instanceClient,
-		Project:        project,
-		Zone:           zone,
-	}, nil
+	client.Endpoint = endpoint
+	return client, nil
 }
 
-type Client struct {
-	InstanceClient *compute.InstancesClient
+// NormalizeEndpoint turns a host like restricted.googleapis.com into an endpoint url
+func NormalizeEndpoint(endpoint string) string {
+	endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+	if endpoint == "" {
+		return defaultEndpoint
+	} else if !strings.HasPrefix(endpoint, "https://") && !strings.HasPrefix(endpoint, "http://") {
+		return "https://" + endpoint
+	}
+
+	return endpoint
+}
 
-	Project string
-	Zone    string
+type Client struct {
+	InstanceClient        *compute.InstancesClient
+	ImageClient           *compute.ImagesClient
+	OperationClient       *compute.ZoneOperationsClient
+	DiskClient            *compute.DisksClient
+	FirewallClient        *compute.FirewallsClient
+	AddressClient         *compute.AddressesClient
+	RouterClient          *compute.RoutersClient
+	SubnetworkClient      *compute.SubnetworksClient
+	MachineImageClient    *compute.MachineImagesClient
+	SnapshotClient        *compute.SnapshotsClient
+	ProjectClient         *compute.ProjectsClient
+	ResourcePolicyClient  *compute.ResourcePoliciesClient
+	MachineTypeClient     *compute.MachineTypesClient
+	AcceleratorTypeClient *compute.AcceleratorTypesClient
+
+	Project  string
+	Zone     string
+	Endpoint string
+
+	httpClient  *http.Client
+	tokenSource oauth2.TokenSource
 }
 
+const (
+	defaultEndpoint    = "https://compute.googleapis.com"
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
 func SetupEnvJson(ctx context.Context) error {
 	if os.Getenv("GCLOUD_JSON_AUTH") != "" {
 		destination := filepath.Join(os.TempDir(), "gcloud_auth.json")
@@ -76,12 +224,12 @@ func ParseToken(tok string) (*oauth2.Token, error) {
 func GetToken(ctx context.Context) ([]byte, error) {
 	tokSource, err := DefaultTokenSource(ctx)
 	if err != nil {
-		return nil, err
+		return nil, &AuthError{Reason: "no credentials found", Err: err}
 	}
 
 	t, err := tokSource.Token()
 	if err != nil {
-		return nil, err
+		return nil, asAuthError(err)
 	}
 
 	t.RefreshToken = ""
@@ -115,6 +263,13 @@ func (c *Client) Create(ctx context.Context, instance *computepb.Instance) error
 }
 
 func (c *Client) Start(ctx context.Context, name string) error {
+	instance, err := c.Get(ctx, name)
+	if err != nil {
+		return err
+	} else if instance != nil && instance.GetStatus() == "SUSPENDED" {
+		return c.Resume(ctx, name)
+	}
+
 	operation, err := c.InstanceClient.Start(ctx, &computepb.StartInstanceRequest{
 		Instance: name,
 		Project:  c.Project,
@@ -127,12 +282,31 @@ func (c *Client) Start(ctx context.Context, name string) error {
 	return operation.Wait(ctx)
 }
 
-func (c *Client) Stop(ctx context.Context, name string, async bool) error {
-	operation, err := c.InstanceClient.Stop(ctx, &computepb.StopInstanceRequest{
+func (c *Client) Resume(ctx context.Context, name string) error {
+	operation, err := c.InstanceClient.Resume(ctx, &computepb.ResumeInstanceRequest{
 		Instance: name,
 		Project:  c.Project,
 		Zone:     c.Zone,
 	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+func (c *Client) Stop(ctx context.Context, name string, discardLocalSSD bool, async bool) error {
+	request := &computepb.StopInstanceRequest{
+		Instance: name,
+		Project:  c.Project,
+		Zone:     c.Zone,
+	}
+	// instances with local ssds only stop if it is explicit what happens to their data
+	if discardLocalSSD {
+		request.DiscardLocalSsd = ptr.Ptr(true)
+	}
+
+	operation, err := c.InstanceClient.Stop(ctx, request)
 	if err != nil {
 		return err
 	} else if async {
@@ -156,19 +330,14 @@ func (c *Client) Delete(ctx context.Context, name string) error {
 }
 
 func (c *Client) Get(ctx context.Context, name string) (*computepb.Instance, error) {
-	instance, err := c.InstanceClient.Get(ctx, &computepb.GetInstanceRequest{
+	instance, err := c.InstanceClient.Get(expectNotFound(ctx), &computepb.GetInstanceRequest{
 		Instance: name,
 		Project:  c.Project,
 		Zone:     c.Zone,
 	})
 	if err != nil {
-		// check if api error
-		apiError, ok := err.(*apierror.APIError)
-		if ok {
-			googleAPIError, ok := apiError.Unwrap().(*googleapi.Error)
-			if ok && googleAPIError.Code == 404 {
-				return nil, nil
-			}
+		if isNotFound(err) {
+			return nil, nil
 		}
 
 		return nil, err
@@ -177,20 +346,75 @@ func (c *Client) Get(ctx context.Context, name string) (*computepb.Instance, err
 	return instance, nil
 }
 
+func isNotFound(err error) bool {
+	// check if api error
+	apiError, ok := err.(*apierror.APIError)
+	if ok {
+		googleAPIError, ok := apiError.Unwrap().(*googleapi.Error)
+		if ok && googleAPIError.Code == 404 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isForbidden(err error) bool {
+	// check if api error
+	apiError, ok := err.(*apierror.APIError)
+	if ok {
+		googleAPIError, ok := apiError.Unwrap().(*googleapi.Error)
+		if ok && googleAPIError.Code == 403 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Client) GetSerialPortOutput(ctx context.Context, name string, start int64) (*computepb.SerialPortOutput, error) {
+	return c.InstanceClient.GetSerialPortOutput(ctx, &computepb.GetSerialPortOutputInstanceRequest{
+		Instance: name,
+		Port:     ptr.Ptr(int32(1)),
+		Start:    ptr.Ptr(start),
+		Project:  c.Project,
+		Zone:     c.Zone,
+	})
+}
+
 func (c *Client) Status(ctx context.Context, name string) (client.Status, error) {
 	instance, err := c.Get(ctx, name)
 	if err != nil {
 		return client.StatusNotFound, err
-	} else if instance == nil {
+	}
+
+	return InstanceStatus(instance)
+}
+
+// InstanceStatus maps the state of an instance fetched before to a devpod status, nil means not found
+func InstanceStatus(instance *computepb.Instance) (client.Status, error) {
+	if instance == nil {
 		return client.StatusNotFound, nil
 	}
 
-	status := strings.TrimSpace(strings.ToUpper(*instance.Status))
-	if status == "RUNNING" {
+	status := strings.TrimSpace(strings.ToUpper(instance.GetStatus()))
+	switch status {
+	case "RUNNING":
 		return client.StatusRunning, nil
-	} else if status == "STOPPING" || status == "SUSPENDING" || status == "REPAIRING" || status == "PROVISIONING" || status == "STAGING" {
+	case "PROVISIONING", "STAGING":
+		// the instance is booting, devpod has to wait before connecting
+		return client.StatusBusy, nil
+	case "STOPPING", "SUSPENDING":
+		// the instance is shutting down, devpod has to wait before starting it again
+		return client.StatusBusy, nil
+	case "REPAIRING":
+		// compute engine is moving the instance to a healthy host, it comes back on its own
 		return client.StatusBusy, nil
-	} else if status == "TERMINATED" {
+	case "SUSPENDED":
+		// suspended instances are resumed by start
+		return client.StatusStopped, nil
+	case "TERMINATED":
+		// stopped by the user or preempted spot instances with termination action STOP, both can be started again
 		return client.StatusStopped, nil
 	}
 
@@ -198,9 +422,24 @@ func (c *Client) Status(ctx context.Context, name string) (client.Status, error)
 }
 
 func (c *Client) Close() error {
-	err := c.InstanceClient.Close()
-	if err != nil {
-		return err
+	return closeAll([]io.Closer{
+		c.InstanceClient, c.ImageClient, c.OperationClient, c.DiskClient, c.FirewallClient, c.AddressClient, c.RouterClient,
+		c.SubnetworkClient, c.MachineImageClient, c.SnapshotClient, c.ProjectClient, c.ResourcePolicyClient, c.MachineTypeClient,
+		c.AcceleratorTypeClient,
+	})
+}
+
+// closeAll closes every client, even if closing one of them fails, and reports all failures
+func closeAll(closers []io.Closer) error {
+	failures := []string{}
+	for _, closer := range closers {
+		err := closer.Close()
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("close api clients: %s", strings.Join(failures, "; "))
 	}
 
 	return nil
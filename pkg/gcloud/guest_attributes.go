@@ -0,0 +1,28 @@
+package gcloud
+
+import (
+	"context"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+)
+
+// GetGuestAttribute returns the guest attribute written by the instance under the given namespace/key,
+// or an empty string if it wasn't written yet
+func (c *Client) GetGuestAttribute(ctx context.Context, name, key string) (string, error) {
+	attributes, err := c.InstanceClient.GetGuestAttributes(expectNotFound(ctx), &computepb.GetGuestAttributesInstanceRequest{
+		Instance:    name,
+		VariableKey: ptr.Ptr(key),
+		Project:     c.Project,
+		Zone:        c.Zone,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return attributes.GetVariableValue(), nil
+}
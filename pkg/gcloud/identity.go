@@ -0,0 +1,46 @@
+package gcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CallerEmail returns the email of the user or service account the provider authenticates as
+func (c *Client) CallerEmail(ctx context.Context) (string, error) {
+	tok, err := c.tokenSource.Token()
+	if err != nil {
+		return "", asAuthError(err)
+	}
+
+	// the token goes into the body, so it doesn't end up in proxy or access logs
+	form := url.Values{"access_token": []string{tok.AccessToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://oauth2.googleapis.com/tokeninfo", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get token info: %s", resp.Status)
+	}
+
+	info := &struct {
+		Email string `json:"email"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(info)
+	if err != nil {
+		return "", err
+	}
+
+	return info.Email, nil
+}
@@ -0,0 +1,168 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+)
+
+// The deep learning vm image family GPU instances boot from, it ships CUDA 12 and installs a matching
+// nvidia driver on first boot
+const (
+	GPUImageProject = "deeplearning-platform-release"
+	GPUImageFamily  = "common-cu123-debian-11-py310"
+)
+
+// FamilyImage returns the image url that always resolves to the latest image in the given family
+func FamilyImage(project, family string) string {
+	return fmt.Sprintf("projects/%s/global/images/family/%s", project, family)
+}
+
+func (c *Client) CreateImage(ctx context.Context, name, family, sourceDisk string) error {
+	operation, err := c.ImageClient.Insert(ctx, &computepb.InsertImageRequest{
+		ImageResource: &computepb.Image{
+			Name:       ptr.Ptr(name),
+			Family:     ptr.Ptr(family),
+			SourceDisk: ptr.Ptr(sourceDisk),
+			Labels: map[string]string{
+				"devpod": "baked",
+			},
+		},
+		Project: c.Project,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+const (
+	DiskSourceImage    = "image"
+	DiskSourceSnapshot = "snapshot"
+	DiskSourceDisk     = "disk"
+)
+
+// DiskSource is the image, snapshot or existing disk a boot disk is created from
+type DiskSource struct {
+	Kind    string
+	Project string
+	Zone    string
+	Name    string
+	Family  bool
+
+	// URL is the normalized resource path, e.g. projects/{{project}}/global/images/{{name}}
+	URL string
+}
+
+var diskSourceRegexes = []struct {
+	kind  string
+	regex *regexp.Regexp
+}{
+	{DiskSourceImage, regexp.MustCompile("^projects/([^/]+)/global/images/(family/)?([^/]+)$")},
+	{DiskSourceSnapshot, regexp.MustCompile("^projects/([^/]+)/global/snapshots/([^/]+)$")},
+	{DiskSourceDisk, regexp.MustCompile("^projects/([^/]+)/zones/([^/]+)/disks/([^/]+)$")},
+}
+
+// ParseDiskSource parses a fully qualified image, snapshot or disk url, which may point to another project.
+// Plain names are treated as images in the given project.
+func ParseDiskSource(source, project string) (*DiskSource, error) {
+	source = strings.TrimSpace(source)
+	for _, prefix := range []string{"https://www.googleapis.com/compute/v1/", "https://compute.googleapis.com/compute/v1/"} {
+		source = strings.TrimPrefix(source, prefix)
+	}
+
+	if !strings.Contains(source, "/") {
+		source = fmt.Sprintf("projects/%s/global/images/%s", project, source)
+	}
+
+	for _, r := range diskSourceRegexes {
+		match := r.regex.FindStringSubmatch(source)
+		if match == nil {
+			continue
+		}
+
+		switch r.kind {
+		case DiskSourceImage:
+			return &DiskSource{Kind: r.kind, Project: match[1], Name: match[3], Family: match[2] != "", URL: source}, nil
+		case DiskSourceSnapshot:
+			return &DiskSource{Kind: r.kind, Project: match[1], Name: match[2], URL: source}, nil
+		case DiskSourceDisk:
+			return &DiskSource{Kind: r.kind, Project: match[1], Zone: match[2], Name: match[3], URL: source}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported disk source %s, expected an image, snapshot or disk url like projects/{{project}}/global/images/{{name}}", source)
+}
+
+// CheckAttachable makes sure an existing disk can be the boot disk of an instance in the given project and
+// zone. Disks aren't copied but attached as they are, which only works in their own project and zone.
+func (s *DiskSource) CheckAttachable(project, zone string) error {
+	if s.Kind != DiskSourceDisk {
+		return nil
+	} else if s.Project != project || s.Zone != zone {
+		return fmt.Errorf("disk %s can't be the boot disk of an instance in zone %s of project %s, existing disks are attached as they are and need to live in the same project and zone. Create a snapshot or image of the disk and use that instead", s.URL, zone, project)
+	}
+
+	return nil
+}
+
+// CheckDiskSourcePermissions verifies the caller may create a boot disk from the given source
+func (c *Client) CheckDiskSourcePermissions(ctx context.Context, source *DiskSource) error {
+	var (
+		resp       *computepb.TestPermissionsResponse
+		permission string
+		err        error
+	)
+
+	switch source.Kind {
+	case DiskSourceImage:
+		name := source.Name
+		if source.Family {
+			image, err := c.ImageClient.GetFromFamily(ctx, &computepb.GetFromFamilyImageRequest{
+				Family:  source.Name,
+				Project: source.Project,
+			})
+			if err != nil {
+				return fmt.Errorf("get latest image of family %s: %w", source.URL, err)
+			}
+
+			name = image.GetName()
+		}
+
+		permission = "compute.images.useReadOnly"
+		resp, err = c.ImageClient.TestIamPermissions(ctx, &computepb.TestIamPermissionsImageRequest{
+			Project:                        source.Project,
+			Resource:                       name,
+			TestPermissionsRequestResource: &computepb.TestPermissionsRequest{Permissions: []string{permission}},
+		})
+	case DiskSourceSnapshot:
+		permission = "compute.snapshots.useReadOnly"
+		resp, err = c.SnapshotClient.TestIamPermissions(ctx, &computepb.TestIamPermissionsSnapshotRequest{
+			Project:                        source.Project,
+			Resource:                       source.Name,
+			TestPermissionsRequestResource: &computepb.TestPermissionsRequest{Permissions: []string{permission}},
+		})
+	case DiskSourceDisk:
+		permission = "compute.disks.use"
+		resp, err = c.DiskClient.TestIamPermissions(ctx, &computepb.TestIamPermissionsDiskRequest{
+			Project:                        source.Project,
+			Zone:                           source.Zone,
+			Resource:                       source.Name,
+			TestPermissionsRequestResource: &computepb.TestPermissionsRequest{Permissions: []string{permission}},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("check permissions on %s %s: %w", source.Kind, source.URL, err)
+	}
+
+	if len(missingPermissions([]string{permission}, resp.GetPermissions())) > 0 {
+		return fmt.Errorf("missing permission %s on %s %s. If it lives in another project, ask its owner to grant you roles/compute.imageUser (images) or roles/compute.storageAdmin (snapshots, disks) there", permission, source.Kind, source.URL)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package gcloud
+
+import (
+	"context"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// SetLabels replaces the labels of the given instance
+func (c *Client) SetLabels(ctx context.Context, instance *computepb.Instance, labels map[string]string) error {
+	operation, err := c.InstanceClient.SetLabels(ctx, &computepb.SetLabelsInstanceRequest{
+		Instance: instance.GetName(),
+		InstancesSetLabelsRequestResource: &computepb.InstancesSetLabelsRequest{
+			LabelFingerprint: instance.LabelFingerprint,
+			Labels:           labels,
+		},
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// SetTags replaces the network tags of the given instance
+func (c *Client) SetTags(ctx context.Context, instance *computepb.Instance, tags []string) error {
+	operation, err := c.InstanceClient.SetTags(ctx, &computepb.SetTagsInstanceRequest{
+		Instance: instance.GetName(),
+		TagsResource: &computepb.Tags{
+			Fingerprint: instance.GetTags().Fingerprint,
+			Items:       tags,
+		},
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
@@ -0,0 +1,104 @@
+package gcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const loggingEndpoint = "https://logging.googleapis.com"
+
+// LogEntry is a single Cloud Logging entry of an instance
+type LogEntry struct {
+	InsertID    string                 `json:"insertId"`
+	Timestamp   time.Time              `json:"timestamp"`
+	LogName     string                 `json:"logName"`
+	Severity    string                 `json:"severity"`
+	TextPayload string                 `json:"textPayload"`
+	JSONPayload map[string]interface{} `json:"jsonPayload"`
+}
+
+// Message returns the log message of the entry
+func (e *LogEntry) Message() string {
+	if e.TextPayload != "" {
+		return e.TextPayload
+	}
+
+	if message, ok := e.JSONPayload["message"].(string); ok {
+		return message
+	}
+
+	out, _ := json.Marshal(e.JSONPayload)
+	return string(out)
+}
+
+// ShortLogName returns the log id without the project prefix, e.g. google_metadata_script_runner
+func (e *LogEntry) ShortLogName() string {
+	return e.LogName[strings.LastIndex(e.LogName, "/")+1:]
+}
+
+// ListInstanceLogs returns the startup script and guest os log entries of the instance with the given id
+// from the given time on, up to until if it isn't zero
+func (c *Client) ListInstanceLogs(ctx context.Context, instanceID uint64, since, until time.Time) ([]*LogEntry, error) {
+
+	filter := fmt.Sprintf(`resource.type="gce_instance" AND resource.labels.instance_id="%d" AND timestamp>="%s" AND (logName="projects/%s/logs/google_metadata_script_runner" OR logName="projects/%s/logs/syslog" OR logName="projects/%s/logs/serialconsole.googleapis.com%%2Fserial_port_1_output")`, instanceID, since.UTC().Format(time.RFC3339Nano), c.Project, c.Project, c.Project)
+
+	if !until.IsZero() {
+		filter += fmt.Sprintf(` AND timestamp<"%s"`, until.UTC().Format(time.RFC3339Nano))
+	}
+
+	entries := []*LogEntry{}
+	pageToken := ""
+	for {
+		body, err := json.Marshal(map[string]interface{}{
+			"resourceNames": []string{"projects/" + c.Project},
+			"filter":        filter,
+			"orderBy":       "timestamp asc",
+			"pageSize":      1000,
+			"pageToken":     pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, loggingEndpoint+"/v2/entries:list", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		} else if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("list log entries: %s", string(out))
+		}
+
+		page := &struct {
+			Entries       []*LogEntry `json:"entries"`
+			NextPageToken string      `json:"nextPageToken"`
+		}{}
+		err = json.Unmarshal(out, page)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, page.Entries...)
+		if page.NextPageToken == "" {
+			return entries, nil
+		}
+
+		pageToken = page.NextPageToken
+	}
+}
@@ -0,0 +1,91 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"google.golang.org/api/iterator"
+)
+
+// CreateMachineImage captures the instance with all its disks and properties. With storageLocation the
+// image is stored in that region or multi-region instead of the one closest to the instance.
+func (c *Client) CreateMachineImage(ctx context.Context, name, instance, storageLocation string) error {
+	machineImage := &computepb.MachineImage{
+		Name:           ptr.Ptr(name),
+		Description:    ptr.Ptr(fmt.Sprintf("DevPod backup of %s", instance)),
+		SourceInstance: ptr.Ptr(fmt.Sprintf("projects/%s/zones/%s/instances/%s", c.Project, c.Zone, instance)),
+	}
+	if storageLocation != "" {
+		machineImage.StorageLocations = []string{storageLocation}
+	}
+
+	operation, err := c.MachineImageClient.Insert(ctx, &computepb.InsertMachineImageRequest{
+		MachineImageResource: machineImage,
+		Project:              c.Project,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// ListMachineImages returns the machine images of the client's project that were created from the
+// given instance, oldest first
+func (c *Client) ListMachineImages(ctx context.Context, instance string) ([]*computepb.MachineImage, error) {
+	machineImages := []*computepb.MachineImage{}
+	it := c.MachineImageClient.List(ctx, &computepb.ListMachineImagesRequest{
+		Project: c.Project,
+		Filter:  ptr.Ptr(fmt.Sprintf(`sourceInstance eq ".*/instances/%s"`, instance)),
+	})
+	for {
+		machineImage, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("list machine images: %w", err)
+		}
+
+		if strings.HasSuffix(machineImage.GetSourceInstance(), "/instances/"+instance) {
+			machineImages = append(machineImages, machineImage)
+		}
+	}
+
+	// creation timestamps carry a UTC offset, so they have to be compared as times
+	sort.SliceStable(machineImages, func(i, j int) bool {
+		return creationTime(machineImages[i]).Before(creationTime(machineImages[j]))
+	})
+
+	return machineImages, nil
+}
+
+// creationTime parses the creation timestamp of the machine image, unparsable ones sort first
+func creationTime(machineImage *computepb.MachineImage) time.Time {
+	created, err := time.Parse(time.RFC3339, machineImage.GetCreationTimestamp())
+	if err != nil {
+		return time.Time{}
+	}
+
+	return created
+}
+
+// CreateFromMachineImage creates the instance from a machine image, which may live in another project.
+// Fields set in the given instance override the properties stored in the machine image.
+func (c *Client) CreateFromMachineImage(ctx context.Context, instance *computepb.Instance, machineImage string) error {
+	operation, err := c.InstanceClient.Insert(ctx, &computepb.InsertInstanceRequest{
+		InstanceResource:   instance,
+		SourceMachineImage: ptr.Ptr(machineImage),
+		Project:            c.Project,
+		Zone:               c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
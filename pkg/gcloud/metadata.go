@@ -0,0 +1,73 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// SetMetadata replaces the metadata of the given instance
+func (c *Client) SetMetadata(ctx context.Context, name string, items []*computepb.Items) error {
+	return c.UpdateMetadata(ctx, name, func([]*computepb.Items) []*computepb.Items {
+		return items
+	})
+}
+
+// UpdateMetadata applies the given change to the current metadata of the instance
+func (c *Client) UpdateMetadata(ctx context.Context, name string, update func(items []*computepb.Items) []*computepb.Items) error {
+	instance, err := c.Get(ctx, name)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		return fmt.Errorf("instance %s doesn't exist", name)
+	}
+
+	operation, err := c.InstanceClient.SetMetadata(ctx, &computepb.SetMetadataInstanceRequest{
+		Instance: name,
+		MetadataResource: &computepb.Metadata{
+			Fingerprint: instance.GetMetadata().Fingerprint,
+			Items:       update(instance.GetMetadata().GetItems()),
+		},
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// ProjectMetadata returns the project-wide metadata that applies to all instances of the project
+func (c *Client) ProjectMetadata(ctx context.Context) (*computepb.Metadata, error) {
+	project, err := c.ProjectClient.Get(ctx, &computepb.GetProjectRequest{
+		Project: c.Project,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get project %s: %w", c.Project, err)
+	}
+
+	return project.GetCommonInstanceMetadata(), nil
+}
+
+// UpdateProjectMetadata applies the given change to the current project-wide metadata
+func (c *Client) UpdateProjectMetadata(ctx context.Context, update func(items []*computepb.Items) []*computepb.Items) error {
+	metadata, err := c.ProjectMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	operation, err := c.ProjectClient.SetCommonInstanceMetadata(ctx, &computepb.SetCommonInstanceMetadataProjectRequest{
+		MetadataResource: &computepb.Metadata{
+			Fingerprint: metadata.Fingerprint,
+			Items:       update(metadata.GetItems()),
+		},
+		Project: c.Project,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
@@ -0,0 +1,35 @@
+package gcloud
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/metrics"
+)
+
+type notFoundExpectedKey struct{}
+
+// expectNotFound marks the requests made with the returned context as handling a missing resource,
+// so their 404 responses aren't counted as api errors
+func expectNotFound(ctx context.Context) context.Context {
+	return context.WithValue(ctx, notFoundExpectedKey{}, true)
+}
+
+// metricsTransport counts failed api requests by their http status code
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		metrics.IncAPIError("error")
+	} else if resp.StatusCode == http.StatusNotFound && req.Context().Value(notFoundExpectedKey{}) != nil {
+		return resp, err
+	} else if resp.StatusCode >= 400 {
+		metrics.IncAPIError(strconv.Itoa(resp.StatusCode))
+	}
+
+	return resp, err
+}
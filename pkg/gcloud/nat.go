@@ -0,0 +1,135 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"google.golang.org/api/iterator"
+)
+
+// ErrNoCloudNAT is returned if no cloud nat gives the subnetwork outbound internet access
+var ErrNoCloudNAT = fmt.Errorf("no cloud nat found")
+
+// ErrCloudNATCheckSkipped is returned if the caller isn't allowed to list the routers of the network
+var ErrCloudNATCheckSkipped = fmt.Errorf("not allowed to list cloud routers, skipping cloud nat check")
+
+// CheckCloudNAT verifies that a cloud nat in the subnetwork's region translates the addresses of the
+// subnetwork, which is the only way for instances without a public ip to reach the internet
+func (c *Client) CheckCloudNAT(ctx context.Context, network, subnetwork string) error {
+	project, region, name, err := parseSubnetwork(subnetwork)
+	if err != nil {
+		return err
+	}
+
+	it := c.RouterClient.List(ctx, &computepb.ListRoutersRequest{
+		Project: project,
+		Region:  region,
+	})
+	for {
+		router, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			if isForbidden(err) {
+				return ErrCloudNATCheckSkipped
+			}
+
+			return fmt.Errorf("list routers of project %s: %w", project, err)
+		}
+
+		if !sameResource(router.GetNetwork(), network) {
+			continue
+		}
+
+		for _, nat := range router.GetNats() {
+			if natsSubnetwork(nat, name) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w for subnetwork %s, instances without a public ip won't have outbound internet access to install packages. Set CREATE_CLOUD_NAT=true to create one during init or create it with: gcloud compute routers create %s --project %s --region %s --network %s && gcloud compute routers nats create devpod-nat --router %s --project %s --region %s --auto-allocate-nat-external-ips --nat-all-subnet-ip-ranges", ErrNoCloudNAT, subnetwork, cloudNATRouterName(network), project, region, path.Base(network), cloudNATRouterName(network), project, region)
+}
+
+// CreateCloudNAT creates a cloud router with a cloud nat for all subnetworks of the network in the
+// subnetwork's region. An existing router created by an earlier call gets the nat added.
+func (c *Client) CreateCloudNAT(ctx context.Context, network, subnetwork string) error {
+	project, region, _, err := parseSubnetwork(subnetwork)
+	if err != nil {
+		return err
+	}
+
+	nat := &computepb.RouterNat{
+		Name:                          ptr.Ptr("devpod-nat"),
+		NatIpAllocateOption:           ptr.Ptr("AUTO_ONLY"),
+		SourceSubnetworkIpRangesToNat: ptr.Ptr("ALL_SUBNETWORKS_ALL_IP_RANGES"),
+	}
+
+	routerName := cloudNATRouterName(network)
+	router, err := c.RouterClient.Get(expectNotFound(ctx), &computepb.GetRouterRequest{
+		Project: project,
+		Region:  region,
+		Router:  routerName,
+	})
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("get router %s: %w", routerName, err)
+	} else if err == nil {
+		operation, err := c.RouterClient.Patch(ctx, &computepb.PatchRouterRequest{
+			Project: project,
+			Region:  region,
+			Router:  routerName,
+			RouterResource: &computepb.Router{
+				Nats: append(router.GetNats(), nat),
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("add nat to router %s: %w", routerName, err)
+		}
+
+		return operation.Wait(ctx)
+	}
+
+	operation, err := c.RouterClient.Insert(ctx, &computepb.InsertRouterRequest{
+		Project: project,
+		Region:  region,
+		RouterResource: &computepb.Router{
+			Name:    ptr.Ptr(routerName),
+			Network: ptr.Ptr(network),
+			Nats:    []*computepb.RouterNat{nat},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create router %s: %w", routerName, err)
+	}
+
+	return operation.Wait(ctx)
+}
+
+func cloudNATRouterName(network string) string {
+	return options.SanitizeName("devpod-nat-" + path.Base(network))
+}
+
+func natsSubnetwork(nat *computepb.RouterNat, subnetwork string) bool {
+	switch nat.GetSourceSubnetworkIpRangesToNat() {
+	case "ALL_SUBNETWORKS_ALL_IP_RANGES", "ALL_SUBNETWORKS_ALL_PRIMARY_IP_RANGES":
+		return true
+	}
+
+	for _, s := range nat.GetSubnetworks() {
+		if path.Base(s.GetName()) == subnetwork {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameResource compares resource urls that might or might not include the api prefix
+func sameResource(a, b string) bool {
+	return strings.TrimPrefix(a, "https://www.googleapis.com/compute/v1/") == strings.TrimPrefix(b, "https://www.googleapis.com/compute/v1/")
+}
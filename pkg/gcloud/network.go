@@ -0,0 +1,149 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+)
+
+// NormalizeNetworkID turns the given network into a full network url, short names are resolved
+// in the given project which is the host project for shared vpcs
+func NormalizeNetworkID(network, project string) *string {
+	network = strings.TrimSpace(network)
+	if len(network) == 0 {
+		return nil
+	}
+
+	// projects/{{project}}/global/networks/{{name}}
+	if regexp.MustCompile("projects/([^/]+)/global/networks/([^/]+)").MatchString(network) {
+		return ptr.Ptr(network)
+	}
+
+	// {{project}}/{{name}}
+	if regexp.MustCompile("([^/]+)/([^/]+)").MatchString(network) {
+		s := strings.Split(network, "/")
+		return ptr.Ptr(fmt.Sprintf("projects/%s/global/networks/%s", s[0], s[1]))
+	}
+
+	// {{name}}
+	return ptr.Ptr(fmt.Sprintf("projects/%s/global/networks/%s", project, network))
+}
+
+// NormalizeSubnetworkID turns the given subnetwork into a full subnetwork url, short names are
+// resolved in the given project and the region of the zone
+func NormalizeSubnetworkID(subnetwork, project, zone string) *string {
+	sn := strings.TrimSpace(subnetwork)
+	if len(sn) == 0 {
+		return nil
+	}
+
+	region := options.RegionOf(zone)
+
+	// projects/{{project}}/regions/{{region}}/subnetworks/{{name}}
+	if regexp.MustCompile("projects/([^/]+)/regions/([^/]+)/subnetworks/([^/]+)").MatchString(sn) {
+		return ptr.Ptr(sn)
+	}
+
+	// {{project}}/{{region}}/{{name}}
+	if regexp.MustCompile("([^/]+)/([^/]+)/([^/]+)").MatchString(sn) {
+		s := strings.Split(sn, "/")
+		return ptr.Ptr(fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", s[0], s[1], s[2]))
+	}
+
+	// {{region}}/{{name}}
+	if regexp.MustCompile("([^/]+)/([^/]+)").MatchString(sn) {
+		s := strings.Split(sn, "/")
+		return ptr.Ptr(fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", project, s[0], s[1]))
+	}
+
+	// {{name}}
+	return ptr.Ptr(fmt.Sprintf("projects/%s/regions/%s/subnetworks/%s", project, region, sn))
+}
+
+// CheckPrivateGoogleAccess makes sure instances without a public ip in the given subnetwork
+// can still reach google apis like Artifact Registry
+func (c *Client) CheckPrivateGoogleAccess(ctx context.Context, subnetwork string) error {
+	project, region, name, err := parseSubnetwork(subnetwork)
+	if err != nil {
+		return err
+	}
+
+	sn, err := c.SubnetworkClient.Get(ctx, &computepb.GetSubnetworkRequest{
+		Project:    project,
+		Region:     region,
+		Subnetwork: name,
+	})
+	if err != nil {
+		return fmt.Errorf("get subnetwork %s: %w", subnetwork, err)
+	}
+
+	if !sn.GetPrivateIpGoogleAccess() {
+		return fmt.Errorf("subnetwork %s doesn't have Private Google Access enabled, instances without a public ip won't be able to pull images. Enable it with: gcloud compute networks subnets update %s --project %s --region %s --enable-private-ip-google-access", subnetwork, name, project, region)
+	}
+
+	return nil
+}
+
+// parseSubnetwork splits projects/{{project}}/regions/{{region}}/subnetworks/{{name}}
+func parseSubnetwork(subnetwork string) (string, string, string, error) {
+	s := strings.Split(strings.TrimPrefix(subnetwork, "https://www.googleapis.com/compute/v1/"), "/")
+	if len(s) != 6 || s[0] != "projects" || s[2] != "regions" || s[4] != "subnetworks" {
+		return "", "", "", fmt.Errorf("unexpected subnetwork %s", subnetwork)
+	}
+
+	return s[1], s[3], s[5], nil
+}
+
+// CheckSubnetworkPermissions verifies that the caller may attach instances to a subnetwork
+// that lives in another project, which is the case for shared vpcs
+func (c *Client) CheckSubnetworkPermissions(ctx context.Context, subnetwork string, externalIP bool) error {
+	project, region, name, err := parseSubnetwork(subnetwork)
+	if err != nil {
+		return err
+	}
+
+	permissions := []string{"compute.subnetworks.use"}
+	if externalIP {
+		permissions = append(permissions, "compute.subnetworks.useExternalIp")
+	}
+
+	resp, err := c.SubnetworkClient.TestIamPermissions(ctx, &computepb.TestIamPermissionsSubnetworkRequest{
+		Project:  project,
+		Region:   region,
+		Resource: name,
+		TestPermissionsRequestResource: &computepb.TestPermissionsRequest{
+			Permissions: permissions,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("check permissions on subnetwork %s: %w", subnetwork, err)
+	}
+
+	missing := missingPermissions(permissions, resp.Permissions)
+	if len(missing) > 0 {
+		return fmt.Errorf("missing permissions %s on subnetwork %s in host project %s. Ask a shared vpc admin to grant you roles/compute.networkUser on the subnetwork or host project", strings.Join(missing, ", "), name, project)
+	}
+
+	return nil
+}
+
+func missingPermissions(wanted, granted []string) []string {
+	grantedMap := map[string]bool{}
+	for _, permission := range granted {
+		grantedMap[permission] = true
+	}
+
+	missing := []string{}
+	for _, permission := range wanted {
+		if !grantedMap[permission] {
+			missing = append(missing, permission)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,101 @@
+package gcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Org policy constraints that make instance creation fail
+const (
+	ConstraintVMExternalIPAccess   = "constraints/compute.vmExternalIpAccess"
+	ConstraintRequireShieldedVM    = "constraints/compute.requireShieldedVm"
+	ConstraintTrustedImageProjects = "constraints/compute.trustedImageProjects"
+	ConstraintRequireOSLogin       = "constraints/compute.requireOsLogin"
+)
+
+// OrgPolicy is the effective org policy of a constraint in a project
+type OrgPolicy struct {
+	Constraint string `json:"constraint"`
+
+	ListPolicy *struct {
+		AllowedValues []string `json:"allowedValues"`
+		DeniedValues  []string `json:"deniedValues"`
+		AllValues     string   `json:"allValues"`
+	} `json:"listPolicy"`
+
+	BooleanPolicy *struct {
+		Enforced bool `json:"enforced"`
+	} `json:"booleanPolicy"`
+}
+
+// Enforced returns if a boolean constraint is enforced
+func (p *OrgPolicy) Enforced() bool {
+	return p.BooleanPolicy != nil && p.BooleanPolicy.Enforced
+}
+
+// Allows returns if a list constraint allows the value, e.g. projects/debian-cloud. Constraints
+// without a policy allow everything.
+func (p *OrgPolicy) Allows(value string) bool {
+	if p.ListPolicy == nil {
+		return true
+	}
+
+	switch p.ListPolicy.AllValues {
+	case "ALLOW":
+		return true
+	case "DENY":
+		return false
+	}
+
+	// resource hierarchy subtrees (under:) can't be resolved from the value alone, so they are
+	// assumed to not deny and to allow it
+	for _, denied := range p.ListPolicy.DeniedValues {
+		if strings.TrimPrefix(denied, "is:") == value {
+			return false
+		}
+	}
+	if len(p.ListPolicy.AllowedValues) == 0 {
+		return true
+	}
+	for _, allowed := range p.ListPolicy.AllowedValues {
+		if strings.HasPrefix(allowed, "under:") || strings.TrimPrefix(allowed, "is:") == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowedValues returns the values a list constraint explicitly allows
+func (p *OrgPolicy) AllowedValues() []string {
+	if p.ListPolicy == nil {
+		return nil
+	}
+
+	values := []string{}
+	for _, value := range p.ListPolicy.AllowedValues {
+		values = append(values, strings.TrimPrefix(value, "is:"))
+	}
+
+	return values
+}
+
+// EffectiveOrgPolicy returns the org policy of the constraint that applies to the client's project
+func (c *Client) EffectiveOrgPolicy(ctx context.Context, constraint string) (*OrgPolicy, error) {
+	out, err := c.resourceManagerRequest(ctx, c.Project, "getEffectiveOrgPolicy", map[string]interface{}{
+		"constraint": constraint,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get org policy %s: %w", constraint, err)
+	}
+
+	policy := &OrgPolicy{}
+	err = json.Unmarshal(out, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,85 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+)
+
+// EnsureCompactPlacementPolicy creates a compact placement policy with the given name in the client's
+// region unless it exists, which places the instances that share it close to each other for low
+// latency networking between gpus
+func (c *Client) EnsureCompactPlacementPolicy(ctx context.Context, name string) error {
+	region := options.RegionOf(c.Zone)
+	_, err := c.ResourcePolicyClient.Get(expectNotFound(ctx), &computepb.GetResourcePolicyRequest{
+		Project:        c.Project,
+		Region:         region,
+		ResourcePolicy: name,
+	})
+	if err == nil {
+		return nil
+	} else if !isNotFound(err) {
+		return fmt.Errorf("get placement policy %s: %w", name, err)
+	}
+
+	operation, err := c.ResourcePolicyClient.Insert(ctx, &computepb.InsertResourcePolicyRequest{
+		Project: c.Project,
+		Region:  region,
+		ResourcePolicyResource: &computepb.ResourcePolicy{
+			Name:        ptr.Ptr(name),
+			Description: ptr.Ptr("Compact placement of devpod instances"),
+			GroupPlacementPolicy: &computepb.ResourcePolicyGroupPlacementPolicy{
+				Collocation: ptr.Ptr(computepb.ResourcePolicyGroupPlacementPolicy_COLLOCATED.String()),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create placement policy %s: %w", name, err)
+	}
+
+	return operation.Wait(ctx)
+}
+
+// SetResourcePolicies replaces the resource policies of the given instance, which needs to be stopped
+// for placement policies
+func (c *Client) SetResourcePolicies(ctx context.Context, name string, existing, desired []string) error {
+	if len(existing) > 0 {
+		operation, err := c.InstanceClient.RemoveResourcePolicies(ctx, &computepb.RemoveResourcePoliciesInstanceRequest{
+			Instance: name,
+			InstancesRemoveResourcePoliciesRequestResource: &computepb.InstancesRemoveResourcePoliciesRequest{
+				ResourcePolicies: existing,
+			},
+			Project: c.Project,
+			Zone:    c.Zone,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = operation.Wait(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(desired) == 0 {
+		return nil
+	}
+
+	operation, err := c.InstanceClient.AddResourcePolicies(ctx, &computepb.AddResourcePoliciesInstanceRequest{
+		Instance: name,
+		InstancesAddResourcePoliciesRequestResource: &computepb.InstancesAddResourcePoliciesRequest{
+			ResourcePolicies: desired,
+		},
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
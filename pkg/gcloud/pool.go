@@ -0,0 +1,177 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// PoolLabel marks instances that belong to the warm pool
+	PoolLabel = "devpod-pool"
+	// PoolSpecLabel holds a hash of the spec a pool instance was created with
+	PoolSpecLabel = "devpod-pool-spec"
+
+	PoolStateAvailable = "available"
+	PoolStateClaimed   = "claimed"
+)
+
+// ListPool returns all pool instances that are provisioned, stopped and not claimed yet
+func (c *Client) ListPool(ctx context.Context) ([]*computepb.Instance, error) {
+	return c.list(ctx, fmt.Sprintf("labels.%s=%s", PoolLabel, PoolStateAvailable))
+}
+
+// CountPool returns the number of unclaimed pool instances with the given spec, including the ones
+// still provisioning
+func (c *Client) CountPool(ctx context.Context, spec string) (int, error) {
+	instances, err := c.list(ctx, fmt.Sprintf("labels.%s:*", PoolLabel))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, instance := range instances {
+		if instance.Labels[PoolLabel] != PoolStateClaimed && instance.Labels[PoolSpecLabel] == spec {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// ClaimPool tries to take over a stopped pool instance with the given spec for the given machine. The
+// instance is renamed, receives the given metadata and is started. Returns false if no instance could
+// be claimed.
+func (c *Client) ClaimPool(ctx context.Context, name, spec string, metadata []*computepb.Items) (bool, error) {
+	instances, err := c.ListPool(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, instance := range instances {
+		if instance.GetStatus() != "TERMINATED" || instance.Labels[PoolSpecLabel] != spec {
+			continue
+		}
+
+		// the label fingerprint makes sure only a single caller can claim the instance
+		labels := map[string]string{}
+		for k, v := range instance.Labels {
+			labels[k] = v
+		}
+		labels[PoolLabel] = PoolStateClaimed
+		operation, err := c.InstanceClient.SetLabels(ctx, &computepb.SetLabelsInstanceRequest{
+			Instance: instance.GetName(),
+			InstancesSetLabelsRequestResource: &computepb.InstancesSetLabelsRequest{
+				LabelFingerprint: instance.LabelFingerprint,
+				Labels:           labels,
+			},
+			Project: c.Project,
+			Zone:    c.Zone,
+		})
+		if err == nil {
+			err = operation.Wait(ctx)
+		}
+		if err != nil {
+			continue
+		}
+
+		err = c.rawZoneOperation(ctx, http.MethodPost, fmt.Sprintf("/projects/%s/zones/%s/instances/%s/setName", c.Project, c.Zone, instance.GetName()), map[string]string{
+			"name":        name,
+			"currentName": instance.GetName(),
+		})
+		if err != nil {
+			// hand the instance back to the pool, so it isn't left behind claimed by nobody
+			releaseErr := c.releasePool(ctx, instance.GetName())
+			if releaseErr != nil {
+				return false, fmt.Errorf("rename pool instance %s: %w, release it: %v", instance.GetName(), err, releaseErr)
+			}
+
+			return false, fmt.Errorf("rename pool instance %s: %w", instance.GetName(), err)
+		}
+
+		// the instance carries the machine's name from here on, so failures are reported as the machine's
+		err = c.finishClaim(ctx, name, metadata)
+		if err != nil {
+			return true, fmt.Errorf("instance %s was claimed from the warm pool as %s: %w", instance.GetName(), name, err)
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// finishClaim turns a renamed pool instance into a regular instance of the machine and starts it
+func (c *Client) finishClaim(ctx context.Context, name string, metadata []*computepb.Items) error {
+	err := c.SetMetadata(ctx, name, metadata)
+	if err != nil {
+		return fmt.Errorf("set metadata: %w", err)
+	}
+
+	instance, err := c.Get(ctx, name)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		return fmt.Errorf("instance %s disappeared", name)
+	}
+
+	labels := map[string]string{}
+	for k, v := range instance.Labels {
+		if k != PoolLabel && k != PoolSpecLabel {
+			labels[k] = v
+		}
+	}
+	err = c.SetLabels(ctx, instance, labels)
+	if err != nil {
+		return fmt.Errorf("remove pool labels: %w", err)
+	}
+
+	err = c.Start(ctx, name)
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+
+	return nil
+}
+
+// releasePool marks a claimed pool instance as available again
+func (c *Client) releasePool(ctx context.Context, name string) error {
+	instance, err := c.Get(ctx, name)
+	if err != nil {
+		return err
+	} else if instance == nil {
+		return nil
+	}
+
+	labels := map[string]string{}
+	for k, v := range instance.Labels {
+		labels[k] = v
+	}
+	labels[PoolLabel] = PoolStateAvailable
+	return c.SetLabels(ctx, instance, labels)
+}
+
+func (c *Client) list(ctx context.Context, filter string) ([]*computepb.Instance, error) {
+	it := c.InstanceClient.List(ctx, &computepb.ListInstancesRequest{
+		Filter:  &filter,
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+
+	instances := []*computepb.Instance{}
+	for {
+		instance, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,44 @@
+package gcloud
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/ptr"
+	"google.golang.org/api/iterator"
+)
+
+// WasPreempted checks if the current stop of the instance was caused by a spot preemption, which
+// shows up as a compute.instances.preempted operation. Preemptions before the instance was last
+// started belong to earlier stops and are ignored.
+func (c *Client) WasPreempted(ctx context.Context, instance *computepb.Instance) (bool, error) {
+	lastStart, err := time.Parse(time.RFC3339, instance.GetLastStartTimestamp())
+	if err != nil {
+		lastStart = time.Time{}
+	}
+
+	it := c.OperationClient.List(ctx, &computepb.ListZoneOperationsRequest{
+		Filter:  ptr.Ptr(`operationType="compute.instances.preempted"`),
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	for {
+		operation, err := it.Next()
+		if err == iterator.Done {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		if !strings.HasSuffix(operation.GetTargetLink(), "/instances/"+instance.GetName()) {
+			continue
+		}
+
+		inserted, err := time.Parse(time.RFC3339, operation.GetInsertTime())
+		if err == nil && inserted.After(lastStart) {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package gcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const resourceManagerEndpoint = "https://cloudresourcemanager.googleapis.com"
+
+// InstancePermissions are needed in a project to manage the instances of workspaces in it
+var InstancePermissions = []string{
+	"compute.instances.create",
+	"compute.instances.delete",
+	"compute.instances.get",
+	"compute.instances.setLabels",
+	"compute.instances.setMetadata",
+	"compute.instances.start",
+	"compute.instances.stop",
+	"compute.disks.create",
+}
+
+// MissingProjectPermissions returns the permissions the caller doesn't have in the given project,
+// which doesn't need to be the project of the client
+func (c *Client) MissingProjectPermissions(ctx context.Context, project string, permissions []string) ([]string, error) {
+	out, err := c.resourceManagerRequest(ctx, project, "testIamPermissions", map[string]interface{}{
+		"permissions": permissions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("test permissions in project %s: %w", project, err)
+	}
+
+	granted := &struct {
+		Permissions []string `json:"permissions"`
+	}{}
+	err = json.Unmarshal(out, granted)
+	if err != nil {
+		return nil, err
+	}
+
+	return missingPermissions(permissions, granted.Permissions), nil
+}
+
+// resourceManagerRequest calls a custom method of the resource manager api on the given project
+func (c *Client) resourceManagerRequest(ctx context.Context, project, method string, body interface{}) ([]byte, error) {
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, resourceManagerEndpoint+"/v1/projects/"+url.PathEscape(project)+":"+method, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s: %s", method, string(out))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,94 @@
+package gcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// rawZoneOperation sends a request for an api method that isn't available in the
+// vendored sdk yet and waits for the returned zone operation to finish
+func (c *Client) rawZoneOperation(ctx context.Context, method, path string, body interface{}) error {
+	out, err := c.rawRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	operation := &struct {
+		Name string `json:"name"`
+	}{}
+	err = json.Unmarshal(out, operation)
+	if err != nil {
+		return err
+	} else if operation.Name == "" {
+		return nil
+	}
+
+	return c.waitZoneOperation(ctx, operation.Name)
+}
+
+func (c *Client) rawRequest(ctx context.Context, method, path string, body interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.Endpoint+"/compute/v1"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s %s: %s", method, path, string(out))
+	}
+
+	return out, nil
+}
+
+func (c *Client) waitZoneOperation(ctx context.Context, name string) error {
+	for {
+		operation, err := c.OperationClient.Wait(ctx, &computepb.WaitZoneOperationRequest{
+			Operation: name,
+			Project:   c.Project,
+			Zone:      c.Zone,
+		})
+		if err != nil {
+			return err
+		}
+
+		if operation.GetStatus() == computepb.Operation_DONE {
+			if operation.Error != nil && len(operation.Error.Errors) > 0 {
+				return fmt.Errorf("operation %s failed: %s", name, operation.Error.Errors[0].GetMessage())
+			}
+
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package gcloud
+
+import (
+	"context"
+
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+)
+
+// SetScheduling replaces the scheduling options of the given instance, changing the provisioning
+// model requires the instance to be stopped
+func (c *Client) SetScheduling(ctx context.Context, name string, scheduling *computepb.Scheduling) error {
+	operation, err := c.InstanceClient.SetScheduling(ctx, &computepb.SetSchedulingInstanceRequest{
+		Instance:           name,
+		SchedulingResource: scheduling,
+		Project:            c.Project,
+		Zone:               c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// SetServiceAccount changes the service account of the given instance, which needs to be stopped
+func (c *Client) SetServiceAccount(ctx context.Context, name string, serviceAccount *computepb.ServiceAccount) error {
+	request := &computepb.InstancesSetServiceAccountRequest{}
+	if serviceAccount != nil {
+		request.Email = serviceAccount.Email
+		request.Scopes = serviceAccount.Scopes
+	}
+
+	operation, err := c.InstanceClient.SetServiceAccount(ctx, &computepb.SetServiceAccountInstanceRequest{
+		Instance: name,
+		InstancesSetServiceAccountRequestResource: request,
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// SetShieldedInstanceConfig changes the shielded vm options of the given instance, which needs to be stopped
+func (c *Client) SetShieldedInstanceConfig(ctx context.Context, name string, config *computepb.ShieldedInstanceConfig) error {
+	operation, err := c.InstanceClient.UpdateShieldedInstanceConfig(ctx, &computepb.UpdateShieldedInstanceConfigInstanceRequest{
+		Instance:                       name,
+		ShieldedInstanceConfigResource: config,
+		Project:                        c.Project,
+		Zone:                           c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// SetMachineType changes the machine type of the given instance, which needs to be stopped
+func (c *Client) SetMachineType(ctx context.Context, name, machineType string) error {
+	operation, err := c.InstanceClient.SetMachineType(ctx, &computepb.SetMachineTypeInstanceRequest{
+		Instance: name,
+		InstancesSetMachineTypeRequestResource: &computepb.InstancesSetMachineTypeRequest{
+			MachineType: &machineType,
+		},
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// SetAccelerators changes the gpus of the given instance, which needs to be stopped
+func (c *Client) SetAccelerators(ctx context.Context, name string, accelerators []*computepb.AcceleratorConfig) error {
+	operation, err := c.InstanceClient.SetMachineResources(ctx, &computepb.SetMachineResourcesInstanceRequest{
+		Instance: name,
+		InstancesSetMachineResourcesRequestResource: &computepb.InstancesSetMachineResourcesRequest{
+			GuestAccelerators: accelerators,
+		},
+		Project: c.Project,
+		Zone:    c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// AttachDisk attaches the given disk to the instance, a disk with initialize params is created first
+func (c *Client) AttachDisk(ctx context.Context, name string, disk *computepb.AttachedDisk) error {
+	operation, err := c.InstanceClient.AttachDisk(ctx, &computepb.AttachDiskInstanceRequest{
+		Instance:             name,
+		AttachedDiskResource: disk,
+		Project:              c.Project,
+		Zone:                 c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
+
+// SetDiskAutoDelete changes if the attached disk with the given device name is deleted together with the instance
+func (c *Client) SetDiskAutoDelete(ctx context.Context, name, deviceName string, autoDelete bool) error {
+	operation, err := c.InstanceClient.SetDiskAutoDelete(ctx, &computepb.SetDiskAutoDeleteInstanceRequest{
+		Instance:   name,
+		DeviceName: deviceName,
+		AutoDelete: autoDelete,
+		Project:    c.Project,
+		Zone:       c.Zone,
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation.Wait(ctx)
+}
@@ -0,0 +1,73 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// locks older than this were left behind by a crashed process
+	staleLockAge = 30 * time.Second
+	// held locks are touched in this interval, so they never look stale
+	refreshInterval = 10 * time.Second
+)
+
+// File acquires an exclusive lock that is shared with other provider processes by creating the
+// given lock file and returns a function that releases it. It gives up after the timeout. The lock
+// can be held for longer than staleLockAge, it is kept fresh until it's released.
+func File(lockPath string, timeout time.Duration) (func(), error) {
+	err := os.MkdirAll(filepath.Dir(lockPath), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, _ = fmt.Fprintf(f, "%d", os.Getpid())
+			_ = f.Close()
+			return keepFresh(lockPath), nil
+		} else if !os.IsExist(err) {
+			return nil, fmt.Errorf("create lock file %s: %w", lockPath, err)
+		}
+
+		if stat, err := os.Stat(lockPath); err == nil && time.Since(stat.ModTime()) > staleLockAge {
+			_ = os.Remove(lockPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock file %s, remove it if no other devpod process is running", lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// keepFresh touches the lock file until the returned function releases it
+func keepFresh(lockPath string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				_ = os.Chtimes(lockPath, now, now)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			_ = os.Remove(lockPath)
+		})
+	}
+}
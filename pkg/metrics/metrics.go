@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/lock"
+)
+
+// Buckets are the upper bounds in seconds of the duration histograms
+var Buckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// Result labels of recorded commands
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+const lockTimeout = 10 * time.Second
+
+// Histogram counts observations per bucket, the last count is for observations above all buckets
+type Histogram struct {
+	Counts []uint64 `json:"counts"`
+	Count  uint64   `json:"count"`
+	Sum    float64  `json:"sum"`
+}
+
+func (h *Histogram) observe(seconds float64) {
+	if len(h.Counts) != len(Buckets)+1 {
+		h.Counts = make([]uint64, len(Buckets)+1)
+	}
+
+	i := 0
+	for i < len(Buckets) && seconds > Buckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += seconds
+}
+
+func (h *Histogram) merge(other *Histogram) {
+	if len(h.Counts) != len(Buckets)+1 {
+		h.Counts = make([]uint64, len(Buckets)+1)
+	}
+
+	for i := range other.Counts {
+		if i < len(h.Counts) {
+			h.Counts[i] += other.Counts[i]
+		}
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+}
+
+// Store holds the metrics of one or more provider invocations
+type Store struct {
+	// CommandDurations by command and result
+	CommandDurations map[string]map[string]*Histogram `json:"commandDurations"`
+	// APIErrors counts failed api requests by http status code
+	APIErrors map[string]uint64 `json:"apiErrors"`
+	// InstanceReady is the time until a created or started instance was ready by command
+	InstanceReady map[string]*Histogram `json:"instanceReady"`
+}
+
+func newStore() *Store {
+	return &Store{
+		CommandDurations: map[string]map[string]*Histogram{},
+		APIErrors:        map[string]uint64{},
+		InstanceReady:    map[string]*Histogram{},
+	}
+}
+
+func (s *Store) merge(other *Store) {
+	for command, results := range other.CommandDurations {
+		for result, histogram := range results {
+			s.commandHistogram(command, result).merge(histogram)
+		}
+	}
+	for code, count := range other.APIErrors {
+		s.APIErrors[code] += count
+	}
+	for command, histogram := range other.InstanceReady {
+		s.readyHistogram(command).merge(histogram)
+	}
+}
+
+func (s *Store) commandHistogram(command, result string) *Histogram {
+	if s.CommandDurations[command] == nil {
+		s.CommandDurations[command] = map[string]*Histogram{}
+	}
+	if s.CommandDurations[command][result] == nil {
+		s.CommandDurations[command][result] = &Histogram{}
+	}
+
+	return s.CommandDurations[command][result]
+}
+
+func (s *Store) readyHistogram(command string) *Histogram {
+	if s.InstanceReady[command] == nil {
+		s.InstanceReady[command] = &Histogram{}
+	}
+
+	return s.InstanceReady[command]
+}
+
+var (
+	currentLock sync.Mutex
+	current     = newStore()
+)
+
+// File returns the file metrics are collected in, metrics are disabled if it is empty
+func File() string {
+	return os.Getenv("METRICS_FILE")
+}
+
+// ObserveCommand records the duration and result of a provider command
+func ObserveCommand(command, result string, d time.Duration) {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	current.commandHistogram(command, result).observe(d.Seconds())
+}
+
+// IncAPIError counts a failed api request
+func IncAPIError(code string) {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	current.APIErrors[code]++
+}
+
+// ObserveInstanceReady records how long it took until a created or started instance was ready
+func ObserveInstanceReady(command string, d time.Duration) {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	current.readyHistogram(command).observe(d.Seconds())
+}
+
+// Flush adds the metrics recorded by this process to the given file, which is shared with other
+// provider processes
+func Flush(path string) error {
+	currentLock.Lock()
+	defer currentLock.Unlock()
+
+	unlock, err := lock.File(path+".lock", lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	store, err := Load(path)
+	if err != nil {
+		return err
+	}
+	store.merge(current)
+
+	out, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := fmt.Sprintf("%s.tmp-%d", path, os.Getpid())
+	err = os.WriteFile(tmpPath, out, 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	current = newStore()
+	return nil
+}
+
+// Load reads the metrics collected in the given file
+func Load(path string) (*Store, error) {
+	store := newStore()
+	out, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	loaded := newStore()
+	err = json.Unmarshal(out, loaded)
+	if err != nil {
+		return nil, fmt.Errorf("parse metrics file %s: %w", path, err)
+	}
+	store.merge(loaded)
+
+	return store, nil
+}
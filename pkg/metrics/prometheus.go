@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes the metrics in the prometheus text exposition format
+func (s *Store) WritePrometheus(w io.Writer) error {
+	b := &strings.Builder{}
+
+	writeHeader(b, "devpod_gcloud_command_duration_seconds", "Duration of provider commands by command and result.", "histogram")
+	for _, command := range sortedKeys(s.CommandDurations) {
+		for _, result := range sortedKeys(s.CommandDurations[command]) {
+			writeHistogram(b, "devpod_gcloud_command_duration_seconds", fmt.Sprintf("command=%q,result=%q", command, result), s.CommandDurations[command][result])
+		}
+	}
+
+	writeHeader(b, "devpod_gcloud_api_errors_total", "Failed google cloud api requests by http status code.", "counter")
+	for _, code := range sortedKeys(s.APIErrors) {
+		fmt.Fprintf(b, "devpod_gcloud_api_errors_total{code=%q} %d\n", code, s.APIErrors[code])
+	}
+
+	writeHeader(b, "devpod_gcloud_instance_ready_seconds", "Time until a created or started instance was ready.", "histogram")
+	for _, command := range sortedKeys(s.InstanceReady) {
+		writeHistogram(b, "devpod_gcloud_instance_ready_seconds", fmt.Sprintf("command=%q", command), s.InstanceReady[command])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHeader(b *strings.Builder, name, help, metricType string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, metricType)
+}
+
+func writeHistogram(b *strings.Builder, name, labels string, h *Histogram) {
+	cumulative := uint64(0)
+	for i, bucket := range Buckets {
+		if i < len(h.Counts) {
+			cumulative += h.Counts[i]
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=%q} %d\n", name, labels, strconv.FormatFloat(bucket, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.Count)
+	fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels, h.Count)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
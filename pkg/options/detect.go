@@ -0,0 +1,136 @@
+package options
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/loft-sh/devpod/pkg/log"
+)
+
+// defaultZone is used if neither ZONE nor a gcloud default zone is set
+const defaultZone = "europe-west2-b"
+
+// projectAndZone returns PROJECT and ZONE, falling back to the active gcloud configuration and, when
+// running on compute engine, the metadata server if they aren't set
+func projectAndZone() (string, string, error) {
+	project := os.Getenv("PROJECT")
+	zone := os.Getenv("ZONE")
+	if project != "" && zone != "" {
+		return project, zone, nil
+	}
+
+	config := gcloudConfig()
+	if project == "" && config["core/project"] != "" {
+		project = config["core/project"]
+		log.Default.ErrorStreamOnly().Infof("Using project %s from the gcloud configuration", project)
+	}
+	if zone == "" && config["compute/zone"] != "" {
+		zone = config["compute/zone"]
+		log.Default.ErrorStreamOnly().Infof("Using zone %s from the gcloud configuration", zone)
+	}
+
+	if (project == "" || zone == "") && metadata.OnGCE() {
+		if project == "" {
+			project, _ = metadata.ProjectID()
+			if project != "" {
+				log.Default.ErrorStreamOnly().Infof("Using project %s of this compute engine instance", project)
+			}
+		}
+		if zone == "" {
+			zone, _ = metadata.Zone()
+			if zone != "" {
+				log.Default.ErrorStreamOnly().Infof("Using zone %s of this compute engine instance", zone)
+			}
+		}
+	}
+
+	if project == "" {
+		return "", "", fmt.Errorf("couldn't find option PROJECT in environment or the gcloud configuration, please define PROJECT or run gcloud config set project")
+	} else if zone == "" {
+		zone = defaultZone
+		log.Default.ErrorStreamOnly().Infof("Using default zone %s, set ZONE or run gcloud config set compute/zone to change it", zone)
+	}
+
+	return project, zone, nil
+}
+
+// gcloudConfig reads the properties of the active gcloud cli configuration as section/name. Properties
+// set through CLOUDSDK_ environment variables take precedence like they do for the cli.
+func gcloudConfig() map[string]string {
+	config := map[string]string{}
+
+	dir := gcloudConfigDir()
+	if dir != "" {
+		active := os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME")
+		if active == "" {
+			out, err := os.ReadFile(filepath.Join(dir, "active_config"))
+			if err == nil {
+				active = strings.TrimSpace(string(out))
+			}
+		}
+		if active == "" {
+			active = "default"
+		}
+
+		readINI(filepath.Join(dir, "configurations", "config_"+active), config)
+	}
+
+	for _, property := range []string{"core/project", "compute/zone"} {
+		env := "CLOUDSDK_" + strings.ToUpper(strings.ReplaceAll(property, "/", "_"))
+		if os.Getenv(env) != "" {
+			config[property] = os.Getenv(env)
+		}
+	}
+
+	return config
+}
+
+func gcloudConfigDir() string {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return dir
+	} else if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud")
+		}
+
+		return ""
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".config", "gcloud")
+}
+
+// readINI adds the key value pairs of the given ini file as section/key to config
+func readINI(path string, config map[string]string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		} else if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if found {
+			config[section+"/"+strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+}
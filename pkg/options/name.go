@@ -0,0 +1,131 @@
+package options
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultNameTemplate is the name template of instances if NAME_TEMPLATE isn't set
+const DefaultNameTemplate = "devpod-{machine}"
+
+// maxNameLength is the maximum length of compute engine resource names
+const maxNameLength = 63
+
+var invalidNameCharsRegEx = regexp.MustCompile("[^a-z0-9-]+")
+
+// machineName renders the name template with the placeholders {machine}, {workspace}, {user} and
+// {context} and sanitizes the result into a valid compute engine resource name
+func machineName(template, machineID string) string {
+	if template == "" {
+		template = DefaultNameTemplate
+	}
+
+	workspaceID := os.Getenv("WORKSPACE_ID")
+	if workspaceID == "" {
+		workspaceID = machineID
+	}
+
+	return SanitizeName(strings.NewReplacer(
+		"{machine}", machineID,
+		"{workspace}", workspaceID,
+		"{user}", CurrentUser(),
+		"{context}", os.Getenv("MACHINE_CONTEXT"),
+	).Replace(template))
+}
+
+// checkNameTemplate makes sure the template yields a different name for every machine
+func checkNameTemplate(template string) error {
+	if template != "" && !strings.Contains(template, "{machine}") && !strings.Contains(template, "{workspace}") {
+		return fmt.Errorf("invalid NAME_TEMPLATE %s, needs to contain {machine} or {workspace} to keep the names of machines apart", template)
+	}
+
+	return nil
+}
+
+// nameFile is where the rendered name of a machine is recorded, below the machine folder
+func nameFile(machineFolder, devPodMachineID string) string {
+	return filepath.Join(machineFolder, "names", devPodMachineID)
+}
+
+// RecordName remembers the rendered name of the machine, so later commands find the instance even
+// if NAME_TEMPLATE, the user or the workspace change in between
+func (o *Options) RecordName() error {
+	if o.Stateless || o.MachineFolder == "" {
+		return nil
+	}
+
+	path := nameFile(o.MachineFolder, o.DevPodMachineID)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(o.MachineID+"\n"), 0644)
+}
+
+// ForgetName removes the recorded name of a deleted machine
+func (o *Options) ForgetName() error {
+	if o.Stateless || o.MachineFolder == "" {
+		return nil
+	}
+
+	err := os.Remove(nameFile(o.MachineFolder, o.DevPodMachineID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// useRecordedName switches to the name the machine was created with
+func (o *Options) useRecordedName() {
+	if o.Stateless || o.MachineFolder == "" {
+		return
+	}
+
+	out, err := os.ReadFile(nameFile(o.MachineFolder, o.DevPodMachineID))
+	if err == nil && strings.TrimSpace(string(out)) != "" {
+		o.MachineID = strings.TrimSpace(string(out))
+	}
+}
+
+// SanitizeName turns the given name into a valid compute engine resource name, which starts with
+// a letter, only contains lowercase letters, digits and dashes and is at most 63 characters long.
+// Names that are too long are shortened and suffixed with a hash to keep them unique.
+func SanitizeName(name string) string {
+	sanitized := invalidNameCharsRegEx.ReplaceAllString(strings.ToLower(name), "-")
+	sanitized = strings.Trim(sanitized, "-")
+	if sanitized == "" || sanitized[0] < 'a' || sanitized[0] > 'z' {
+		sanitized = "devpod-" + sanitized
+	}
+
+	if len(sanitized) > maxNameLength {
+		hash := sha256.Sum256([]byte(name))
+		suffix := hex.EncodeToString(hash[:])[:8]
+		sanitized = strings.TrimRight(sanitized[:maxNameLength-len(suffix)-1], "-") + "-" + suffix
+	}
+
+	return strings.TrimRight(sanitized, "-")
+}
+
+// CurrentUser returns the name of the local user without the domain
+func CurrentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return os.Getenv("USER")
+	}
+
+	// strip the domain of windows users
+	_, username, found := strings.Cut(u.Username, "\\")
+	if found {
+		return username
+	}
+
+	return u.Username
+}
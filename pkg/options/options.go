@@ -3,20 +3,173 @@ package options
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// hostnameRegEx matches RFC-1035 fully qualified domain names with at least two labels
+var hostnameRegEx = regexp.MustCompile(`^([a-z]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// filestoreRegEx matches the mount point of a filestore share, e.g. 10.0.0.2:/share1
+var filestoreRegEx = regexp.MustCompile(`^[0-9.]+:/[A-Za-z0-9_/-]+$`)
+
+var mountPathRegEx = regexp.MustCompile(`^/[A-Za-z0-9._/-]+$`)
+
+// gcsBucketMountRegEx matches bucket[/dir][:/mount/path]
+var gcsBucketMountRegEx = regexp.MustCompile(`^([a-z0-9][a-z0-9._-]{1,220}[a-z0-9])(/[A-Za-z0-9._/-]+)?(:/[A-Za-z0-9._/-]+)?$`)
+
+// DefaultDiskImage is the image instances boot from without DISK_IMAGE, IMAGE_FAMILY or GPU_IMAGE
+const DefaultDiskImage = "projects/cos-cloud/global/images/cos-101-17162-127-5"
+
+// zoneRegEx matches zones like us-central1-a
+var zoneRegEx = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+-[a-z0-9]+$`)
+
+// placementPolicyRegEx matches the name of a placement policy in the zone's region
+var placementPolicyRegEx = regexp.MustCompile(`^[a-z]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+var labelKeyRegEx = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+
+var labelValueRegEx = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+
 type Options struct {
-	MachineID     string
-	MachineFolder string
-
-	Project     string
-	Zone        string
-	Network     string
-	Subnetwork  string
-	Tag         string
-	DiskSize    string
-	DiskImage   string
-	MachineType string
+	// MachineID is the name of the instance, DevPodMachineID the id devpod knows the machine by
+	MachineID       string
+	DevPodMachineID string
+	MachineFolder   string
+	Stateless       bool
+
+	Project        string
+	Zone           string
+	Network        string
+	Subnetwork     string
+	NetworkProject string
+	Tag            string
+	StackType      string
+	NicType        string
+	NetworkTier    string
+	NoPublicIP     bool
+	OrgPolicyMode  string
+	APIEndpoint    string
+	DiskSize       string
+	DiskType       string
+	DiskImage      string
+	MachineType    string
+	ImageFamily    string
+	Hostname       string
+
+	ProvisionedIops       int
+	ProvisionedThroughput int
+
+	DataDiskSize     int
+	LocalSSDCount    int
+	RelocateAgentDir bool
+
+	AcceleratorType  string
+	AcceleratorCount int
+	GPUImage         bool
+
+	StopGracePeriod time.Duration
+	PreStopCommand  string
+
+	Spot                  bool
+	SpotTerminationAction string
+	SpotAutoRecover       bool
+
+	KeepDiskOnDelete bool
+
+	AutomaticRestart  bool
+	OnHostMaintenance string
+	PlacementPolicy   string
+	ShieldedVM        bool
+
+	AgentPath      string
+	StartupScript  string
+	ReadyTimeout   time.Duration
+	CustomMetadata string
+	Labels         map[string]string
+	Templating     bool
+	ServiceAccount string
+
+	InstallOpsAgent bool
+
+	DockerCredentialHelper bool
+	DockerRegistries       []string
+
+	FilestoreShare string
+	FilestorePath  string
+
+	GCSBucket    string
+	GCSBucketDir string
+	GCSMountPath string
+
+	WarmPoolSize int
+
+	BastionHost string
+	BastionUser string
+
+	AdditionalNetworkInterfaces string
+	CreateCloudNAT              bool
+
+	SSHKeyRotationDays int
+	SSHKeyType         string
+	SSHPrivateKeyPath  string
+	SSHPrivateKey      string
+	SSHPublicKey       string
+	SSHAgent           bool
+	SSHAgentForwarding bool
+
+	BlockProjectSSHKeys bool
+}
+
+// Region returns the region of the zone, e.g. us-central1 for us-central1-a
+func (o *Options) Region() string {
+	return RegionOf(o.Zone)
+}
+
+// RegionOf returns the region of the given zone
+func RegionOf(zone string) string {
+	i := strings.LastIndex(zone, "-")
+	if i < 0 {
+		return zone
+	}
+
+	return zone[:i]
+}
+
+// UseGPUImage checks if the instance boots from a deep learning vm image that ships cuda and the
+// nvidia driver. An explicit DISK_IMAGE or IMAGE_FAMILY takes precedence over GPU_IMAGE.
+func (o *Options) UseGPUImage() bool {
+	return o.GPUImage && o.HasGPU() && o.ImageFamily == "" && o.DiskImage == ""
+}
+
+// HasGPU checks if the instance gets an accelerator or uses a machine family with built-in gpus
+func (o *Options) HasGPU() bool {
+	return o.AcceleratorType != "" || acceleratorOptimized(o.MachineType)
+}
+
+// acceleratorOptimized checks if the machine type belongs to a family that comes with gpus attached
+func acceleratorOptimized(machineType string) bool {
+	for _, family := range []string{"a2-", "a3-", "g2-"} {
+		if strings.HasPrefix(machineType, family) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gpuNetworkInterfaces is the number of gpu nics the a3 machine types use for GPUDirect
+var gpuNetworkInterfaces = map[string]int{
+	"a3-highgpu-8g": 4,
+	"a3-megagpu-8g": 8,
+}
+
+// GPUNetworkInterfaces returns the number of gpu nics the machine type needs for GPUDirect, each in its
+// own vpc, or 0 if it doesn't support GPUDirect
+func (o *Options) GPUNetworkInterfaces() int {
+	return gpuNetworkInterfaces[o.MachineType]
 }
 
 func FromEnv(withMachine bool) (*Options, error) {
@@ -24,47 +177,387 @@ func FromEnv(withMachine bool) (*Options, error) {
 
 	var err error
 	if withMachine {
-		retOptions.MachineID, err = fromEnvOrError("MACHINE_ID")
+		retOptions.DevPodMachineID, err = fromEnvOrError("MACHINE_ID")
 		if err != nil {
 			return nil, err
 		}
-		// prefix with devpod-
-		retOptions.MachineID = "devpod-" + retOptions.MachineID
+		nameTemplate := os.Getenv("NAME_TEMPLATE")
+		err = checkNameTemplate(nameTemplate)
+		if err != nil {
+			return nil, err
+		}
+		retOptions.MachineID = machineName(nameTemplate, retOptions.DevPodMachineID)
 
-		retOptions.MachineFolder, err = fromEnvOrError("MACHINE_FOLDER")
+		// stateless machines don't keep anything in the machine folder, so it may be missing
+		retOptions.Stateless, err = boolFromEnv("STATELESS")
 		if err != nil {
 			return nil, err
+		} else if !retOptions.Stateless {
+			retOptions.MachineFolder = os.Getenv("MACHINE_FOLDER_OVERRIDE")
+			if retOptions.MachineFolder == "" {
+				retOptions.MachineFolder, err = fromEnvOrError("MACHINE_FOLDER")
+				if err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
 
-	retOptions.Project, err = fromEnvOrError("PROJECT")
+	retOptions.Project, retOptions.Zone, err = projectAndZone()
 	if err != nil {
 		return nil, err
+	} else if !zoneRegEx.MatchString(retOptions.Zone) {
+		return nil, fmt.Errorf("invalid ZONE %s, needs to be a zone like us-central1-a", retOptions.Zone)
 	}
-	retOptions.Zone, err = fromEnvOrError("ZONE")
+	retOptions.DiskSize, err = fromEnvOrError("DISK_SIZE")
 	if err != nil {
 		return nil, err
 	}
-	retOptions.DiskSize, err = fromEnvOrError("DISK_SIZE")
+	retOptions.DiskImage = os.Getenv("DISK_IMAGE")
+	retOptions.MachineType, err = fromEnvOrError("MACHINE_TYPE")
 	if err != nil {
 		return nil, err
 	}
-	retOptions.DiskImage, err = fromEnvOrError("DISK_IMAGE")
+
+	retOptions.Network = os.Getenv("NETWORK")
+	retOptions.Subnetwork = os.Getenv("SUBNETWORK")
+	retOptions.NetworkProject = os.Getenv("NETWORK_PROJECT")
+	retOptions.Tag = os.Getenv("TAG")
+	retOptions.StackType = os.Getenv("STACK_TYPE")
+	if retOptions.StackType == "" {
+		retOptions.StackType = "IPV4_ONLY"
+	} else if retOptions.StackType != "IPV4_ONLY" && retOptions.StackType != "IPV4_IPV6" {
+		return nil, fmt.Errorf("unsupported STACK_TYPE %s, needs to be one of IPV4_ONLY or IPV4_IPV6", retOptions.StackType)
+	}
+	retOptions.ImageFamily = os.Getenv("IMAGE_FAMILY")
+	retOptions.StartupScript = os.Getenv("STARTUP_SCRIPT")
+	retOptions.CustomMetadata = os.Getenv("CUSTOM_METADATA")
+	retOptions.Labels, err = parseLabels(os.Getenv("LABELS"))
 	if err != nil {
 		return nil, err
 	}
-	retOptions.MachineType, err = fromEnvOrError("MACHINE_TYPE")
+	retOptions.Templating, err = boolFromEnv("TEMPLATING")
 	if err != nil {
 		return nil, err
 	}
+	retOptions.AgentPath = os.Getenv("AGENT_PATH")
+	if retOptions.AgentPath == "" {
+		retOptions.AgentPath = "/var/lib/toolbox/devpod"
+	}
 
-	retOptions.Network = os.Getenv("NETWORK")
-	retOptions.Subnetwork = os.Getenv("SUBNETWORK")
-	retOptions.Tag = os.Getenv("TAG")
+	retOptions.NicType = os.Getenv("NIC_TYPE")
+	if retOptions.NicType != "" && retOptions.NicType != "GVNIC" && retOptions.NicType != "VIRTIO_NET" {
+		return nil, fmt.Errorf("unsupported NIC_TYPE %s, needs to be one of GVNIC or VIRTIO_NET", retOptions.NicType)
+	} else if strings.HasPrefix(retOptions.MachineType, "a3-") {
+		// a3 machines only support gvnic
+		if retOptions.NicType == "VIRTIO_NET" {
+			return nil, fmt.Errorf("machine type %s requires NIC_TYPE GVNIC", retOptions.MachineType)
+		}
+		retOptions.NicType = "GVNIC"
+	}
+	retOptions.NetworkTier = os.Getenv("NETWORK_PERFORMANCE_TIER")
+	if retOptions.NetworkTier != "" && retOptions.NetworkTier != "DEFAULT" && retOptions.NetworkTier != "TIER_1" {
+		return nil, fmt.Errorf("unsupported NETWORK_PERFORMANCE_TIER %s, needs to be one of DEFAULT or TIER_1", retOptions.NetworkTier)
+	} else if retOptions.NetworkTier == "TIER_1" && retOptions.NicType != "GVNIC" {
+		return nil, fmt.Errorf("NETWORK_PERFORMANCE_TIER TIER_1 requires NIC_TYPE GVNIC")
+	}
+
+	retOptions.APIEndpoint = os.Getenv("API_ENDPOINT")
+	retOptions.NoPublicIP, err = boolFromEnv("NO_PUBLIC_IP")
+	if err != nil {
+		return nil, err
+	}
+	retOptions.OrgPolicyMode = os.Getenv("ORG_POLICY_MODE")
+	if retOptions.OrgPolicyMode == "" {
+		retOptions.OrgPolicyMode = "check"
+	} else if retOptions.OrgPolicyMode != "check" && retOptions.OrgPolicyMode != "adjust" && retOptions.OrgPolicyMode != "off" {
+		return nil, fmt.Errorf("unsupported ORG_POLICY_MODE %s, needs to be one of check, adjust or off", retOptions.OrgPolicyMode)
+	}
+
+	retOptions.Hostname = os.Getenv("INSTANCE_HOSTNAME")
+	if retOptions.Hostname != "" && (len(retOptions.Hostname) > 253 || !hostnameRegEx.MatchString(retOptions.Hostname)) {
+		return nil, fmt.Errorf("invalid INSTANCE_HOSTNAME %s, needs to be a fully qualified domain name like devpod.example.internal with lowercase labels of at most 63 characters", retOptions.Hostname)
+	}
+	retOptions.AdditionalNetworkInterfaces = os.Getenv("ADDITIONAL_NETWORK_INTERFACES")
+	retOptions.CreateCloudNAT, err = boolFromEnv("CREATE_CLOUD_NAT")
+	if err != nil {
+		return nil, err
+	}
+	retOptions.BastionHost = os.Getenv("BASTION_HOST")
+	retOptions.BastionUser = os.Getenv("BASTION_USER")
+	if retOptions.BastionUser == "" {
+		retOptions.BastionUser = "devpod"
+	}
+
+	retOptions.DiskType = os.Getenv("DISK_TYPE")
+	if retOptions.DiskType == "" {
+		retOptions.DiskType = "pd-balanced"
+	}
+	retOptions.ProvisionedIops, err = intFromEnv("PROVISIONED_IOPS")
+	if err != nil {
+		return nil, err
+	} else if retOptions.ProvisionedIops > 0 && retOptions.DiskType != "pd-extreme" && retOptions.DiskType != "hyperdisk-balanced" && retOptions.DiskType != "hyperdisk-extreme" {
+		return nil, fmt.Errorf("PROVISIONED_IOPS is only supported for DISK_TYPE pd-extreme, hyperdisk-balanced and hyperdisk-extreme")
+	}
+	retOptions.ProvisionedThroughput, err = intFromEnv("PROVISIONED_THROUGHPUT")
+	if err != nil {
+		return nil, err
+	} else if retOptions.ProvisionedThroughput > 0 && retOptions.DiskType != "hyperdisk-balanced" && retOptions.DiskType != "hyperdisk-throughput" {
+		return nil, fmt.Errorf("PROVISIONED_THROUGHPUT is only supported for DISK_TYPE hyperdisk-balanced and hyperdisk-throughput")
+	}
+
+	retOptions.DataDiskSize, err = intFromEnv("DATA_DISK_SIZE")
+	if err != nil {
+		return nil, err
+	} else if retOptions.DataDiskSize < 0 {
+		return nil, fmt.Errorf("DATA_DISK_SIZE needs to be 0 or more")
+	}
+	retOptions.LocalSSDCount, err = intFromEnv("LOCAL_SSD_COUNT")
+	if err != nil {
+		return nil, err
+	} else if retOptions.LocalSSDCount < 0 || retOptions.LocalSSDCount > 24 {
+		return nil, fmt.Errorf("LOCAL_SSD_COUNT needs to be between 0 and 24")
+	} else if retOptions.LocalSSDCount > 0 && retOptions.DataDiskSize > 0 {
+		return nil, fmt.Errorf("DATA_DISK_SIZE and LOCAL_SSD_COUNT can't be used together")
+	}
+	retOptions.RelocateAgentDir, err = boolFromEnv("RELOCATE_AGENT_DIR")
+	if err != nil {
+		return nil, err
+	} else if retOptions.RelocateAgentDir && retOptions.DataDiskSize == 0 && retOptions.LocalSSDCount == 0 {
+		return nil, fmt.Errorf("RELOCATE_AGENT_DIR requires DATA_DISK_SIZE or LOCAL_SSD_COUNT")
+	}
+
+	retOptions.ServiceAccount = os.Getenv("SERVICE_ACCOUNT")
+	retOptions.InstallOpsAgent, err = boolFromEnv("INSTALL_OPS_AGENT")
+	if err != nil {
+		return nil, err
+	}
+
+	retOptions.DockerCredentialHelper, err = boolFromEnv("DOCKER_CREDENTIAL_HELPER")
+	if err != nil {
+		return nil, err
+	}
+	for _, registry := range strings.Split(os.Getenv("DOCKER_REGISTRIES"), ",") {
+		registry = strings.TrimSpace(registry)
+		if registry == "" {
+			continue
+		} else if !hostnameRegEx.MatchString(registry) {
+			return nil, fmt.Errorf("invalid DOCKER_REGISTRIES entry %s, needs to be a registry host like europe-docker.pkg.dev", registry)
+		}
+
+		retOptions.DockerRegistries = append(retOptions.DockerRegistries, registry)
+	}
+	if len(retOptions.DockerRegistries) == 0 {
+		retOptions.DockerRegistries = []string{"gcr.io", retOptions.Region() + "-docker.pkg.dev", "us-docker.pkg.dev", "europe-docker.pkg.dev", "asia-docker.pkg.dev"}
+	}
+
+	retOptions.FilestoreShare = os.Getenv("FILESTORE_SHARE")
+	if retOptions.FilestoreShare != "" && !filestoreRegEx.MatchString(retOptions.FilestoreShare) {
+		return nil, fmt.Errorf("invalid FILESTORE_SHARE %s, needs to be the mount point of the share like 10.0.0.2:/share1", retOptions.FilestoreShare)
+	}
+	if gcsBucketMount := os.Getenv("GCS_BUCKET_MOUNT"); gcsBucketMount != "" {
+		matches := gcsBucketMountRegEx.FindStringSubmatch(gcsBucketMount)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid GCS_BUCKET_MOUNT %s, needs to be bucket[/dir][:/mount/path]", gcsBucketMount)
+		}
+
+		retOptions.GCSBucket = matches[1]
+		retOptions.GCSBucketDir = strings.Trim(matches[2], "/")
+		retOptions.GCSMountPath = strings.TrimPrefix(matches[3], ":")
+		if retOptions.GCSMountPath == "" {
+			retOptions.GCSMountPath = "/mnt/gcs/" + retOptions.GCSBucket
+		}
+	}
+	retOptions.FilestorePath = os.Getenv("FILESTORE_PATH")
+	if retOptions.FilestorePath == "" {
+		retOptions.FilestorePath = "/mnt/filestore"
+	} else if !mountPathRegEx.MatchString(retOptions.FilestorePath) {
+		return nil, fmt.Errorf("invalid FILESTORE_PATH %s, needs to be an absolute path of letters, digits, '.', '_' and '-'", retOptions.FilestorePath)
+	}
+
+	retOptions.ReadyTimeout, err = durationFromEnv("READY_TIMEOUT", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	retOptions.AcceleratorType = os.Getenv("ACCELERATOR_TYPE")
+	if retOptions.AcceleratorType != "" && acceleratorOptimized(retOptions.MachineType) {
+		return nil, fmt.Errorf("machine type %s comes with gpus attached, unset ACCELERATOR_TYPE", retOptions.MachineType)
+	}
+	retOptions.AcceleratorCount, err = intFromEnv("ACCELERATOR_COUNT")
+	if err != nil {
+		return nil, err
+	} else if retOptions.AcceleratorCount == 0 {
+		retOptions.AcceleratorCount = 1
+	}
+	retOptions.GPUImage, err = boolFromEnv("GPU_IMAGE")
+	if err != nil {
+		return nil, err
+	}
+
+	retOptions.Spot, err = boolFromEnv("SPOT")
+	if err != nil {
+		return nil, err
+	}
+	retOptions.SpotTerminationAction = os.Getenv("SPOT_TERMINATION_ACTION")
+	if retOptions.SpotTerminationAction == "" {
+		retOptions.SpotTerminationAction = "STOP"
+	} else if retOptions.SpotTerminationAction != "STOP" && retOptions.SpotTerminationAction != "DELETE" {
+		return nil, fmt.Errorf("unsupported SPOT_TERMINATION_ACTION %s, needs to be one of STOP or DELETE", retOptions.SpotTerminationAction)
+	}
+	retOptions.SpotAutoRecover, err = boolFromEnv("SPOT_AUTO_RECOVER")
+	if err != nil {
+		return nil, err
+	}
+
+	retOptions.KeepDiskOnDelete, err = boolFromEnv("KEEP_DISK_ON_DELETE")
+	if err != nil {
+		return nil, err
+	}
+
+	// spot instances are never restarted, so only an explicit true is rejected for them
+	retOptions.AutomaticRestart = !retOptions.Spot
+	if os.Getenv("AUTOMATIC_RESTART") != "" {
+		retOptions.AutomaticRestart, err = boolFromEnv("AUTOMATIC_RESTART")
+		if err != nil {
+			return nil, err
+		} else if retOptions.AutomaticRestart && retOptions.Spot {
+			return nil, fmt.Errorf("spot instances can't be restarted automatically, unset AUTOMATIC_RESTART")
+		}
+	}
+	retOptions.OnHostMaintenance = os.Getenv("ON_HOST_MAINTENANCE")
+	if retOptions.OnHostMaintenance != "" && retOptions.OnHostMaintenance != "MIGRATE" && retOptions.OnHostMaintenance != "TERMINATE" {
+		return nil, fmt.Errorf("unsupported ON_HOST_MAINTENANCE %s, needs to be one of MIGRATE or TERMINATE", retOptions.OnHostMaintenance)
+	} else if retOptions.OnHostMaintenance == "MIGRATE" && (retOptions.Spot || retOptions.HasGPU()) {
+		return nil, fmt.Errorf("spot instances and instances with gpus can't be live migrated, set ON_HOST_MAINTENANCE to TERMINATE")
+	}
+	retOptions.PlacementPolicy = os.Getenv("PLACEMENT_POLICY")
+	if retOptions.PlacementPolicy != "" && !strings.Contains(retOptions.PlacementPolicy, "/") && !placementPolicyRegEx.MatchString(retOptions.PlacementPolicy) {
+		return nil, fmt.Errorf("invalid PLACEMENT_POLICY %s, needs to be the name of a placement policy that the machines placed together share, e.g. team-a-training", retOptions.PlacementPolicy)
+	} else if retOptions.PlacementPolicy != "" && retOptions.OnHostMaintenance == "MIGRATE" {
+		return nil, fmt.Errorf("instances with a placement policy can't be live migrated, set ON_HOST_MAINTENANCE to TERMINATE")
+	}
+	retOptions.ShieldedVM, err = boolFromEnv("SHIELDED_VM")
+	if err != nil {
+		return nil, err
+	}
+
+	retOptions.StopGracePeriod, err = durationFromEnv("STOP_GRACE_PERIOD", 0)
+	if err != nil {
+		return nil, err
+	}
+	retOptions.PreStopCommand = os.Getenv("PRE_STOP_COMMAND")
+
+	retOptions.WarmPoolSize, err = intFromEnv("WARM_POOL_SIZE")
+	if err != nil {
+		return nil, err
+	}
+
+	retOptions.SSHKeyRotationDays, err = intFromEnv("SSH_KEY_ROTATION_DAYS")
+	if err != nil {
+		return nil, err
+	}
+	retOptions.SSHKeyType = os.Getenv("SSH_KEY_TYPE")
+	if retOptions.SSHKeyType == "" {
+		retOptions.SSHKeyType = "rsa-2048"
+	} else if retOptions.SSHKeyType != "rsa-2048" && retOptions.SSHKeyType != "rsa-4096" && retOptions.SSHKeyType != "ecdsa-p256" && retOptions.SSHKeyType != "ed25519" {
+		return nil, fmt.Errorf("unsupported SSH_KEY_TYPE %s, needs to be one of rsa-2048, rsa-4096, ecdsa-p256 or ed25519", retOptions.SSHKeyType)
+	}
+	retOptions.SSHPrivateKeyPath = os.Getenv("SSH_PRIVATE_KEY_PATH")
+	retOptions.SSHPrivateKey = os.Getenv("SSH_PRIVATE_KEY")
+	if retOptions.SSHPrivateKey != "" && retOptions.SSHPrivateKeyPath != "" {
+		return nil, fmt.Errorf("SSH_PRIVATE_KEY and SSH_PRIVATE_KEY_PATH can't be used together")
+	}
+	retOptions.SSHPublicKey = os.Getenv("SSH_PUBLIC_KEY")
+	if retOptions.Stateless && retOptions.SSHPrivateKey == "" && retOptions.SSHPrivateKeyPath == "" && retOptions.SSHPublicKey == "" {
+		return nil, fmt.Errorf("STATELESS requires the key to be passed with SSH_PRIVATE_KEY, SSH_PRIVATE_KEY_PATH or SSH_PUBLIC_KEY")
+	}
+	retOptions.SSHAgent, err = boolFromEnv("SSH_AGENT")
+	if err != nil {
+		return nil, err
+	}
+	retOptions.SSHAgentForwarding, err = boolFromEnv("SSH_AGENT_FORWARDING")
+	if err != nil {
+		return nil, err
+	}
+	retOptions.BlockProjectSSHKeys, err = boolFromEnv("BLOCK_PROJECT_SSH_KEYS")
+	if err != nil {
+		return nil, err
+	}
+
+	if withMachine {
+		retOptions.useRecordedName()
+		retOptions.useRecordedProject()
+		retOptions.useRecordedZone()
+	}
 
 	return retOptions, nil
 }
 
+// parseLabels parses comma or newline separated key=value pairs
+func parseLabels(raw string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, entry := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if !labelKeyRegEx.MatchString(key) || !labelValueRegEx.MatchString(value) {
+			return nil, fmt.Errorf("invalid LABELS entry %q, keys and values may only contain lowercase letters, digits, '_' and '-' and keys need to start with a letter", entry)
+		}
+
+		labels[key] = value
+	}
+
+	return labels, nil
+}
+
+func boolFromEnv(name string) (bool, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("parse option %s: %w", name, err)
+	}
+
+	return b, nil
+}
+
+func durationFromEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return defaultValue, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("parse option %s: %w", name, err)
+	}
+
+	return d, nil
+}
+
+func intFromEnv(name string) (int, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("parse option %s: %w", name, err)
+	}
+
+	return i, nil
+}
+
 func fromEnvOrError(name string) (string, error) {
 	val := os.Getenv(name)
 	if val == "" {
@@ -0,0 +1,71 @@
+package options
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/loft-sh/devpod/pkg/log"
+)
+
+// projectFile is where the project a machine was created in is recorded, below the machine folder
+func projectFile(machineFolder, machineID string) string {
+	return filepath.Join(machineFolder, "projects", machineID)
+}
+
+// recordedProject returns the project the machine was created in or an empty string if it wasn't recorded
+func recordedProject(machineFolder, machineID string) string {
+	out, err := os.ReadFile(projectFile(machineFolder, machineID))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// RecordProject remembers the machine's project, so later commands find the machine there even if
+// PROJECT changes in between. Stateless machines need PROJECT to be passed every time.
+func (o *Options) RecordProject() error {
+	if o.Stateless || o.MachineFolder == "" {
+		return nil
+	}
+
+	path := projectFile(o.MachineFolder, o.MachineID)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(o.Project+"\n"), 0644)
+}
+
+// ForgetProject removes the recorded project of a deleted machine
+func (o *Options) ForgetProject() error {
+	if o.Stateless || o.MachineFolder == "" {
+		return nil
+	}
+
+	err := os.Remove(projectFile(o.MachineFolder, o.MachineID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// useRecordedProject switches to the project the machine was created in, unless PROJECT_OVERRIDE
+// explicitly sets the machine's project
+func (o *Options) useRecordedProject() {
+	if override := os.Getenv("PROJECT_OVERRIDE"); override != "" {
+		o.Project = override
+		return
+	} else if o.Stateless || o.MachineFolder == "" {
+		return
+	}
+
+	project := recordedProject(o.MachineFolder, o.MachineID)
+	if project != "" && project != o.Project {
+		log.Default.ErrorStreamOnly().Warnf("Using project %s that machine %s was created in instead of PROJECT %s, set PROJECT_OVERRIDE to use another project for the machine", project, o.MachineID, o.Project)
+		o.Project = project
+	}
+}
@@ -0,0 +1,67 @@
+package options
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/loft-sh/devpod/pkg/log"
+)
+
+// zoneFile is where the zone a machine was moved to is recorded, below the machine folder
+func zoneFile(machineFolder, machineID string) string {
+	return filepath.Join(machineFolder, "zones", machineID)
+}
+
+// recordedZone returns the zone the machine was restored into or an empty string if it wasn't moved
+func recordedZone(machineFolder, machineID string) string {
+	out, err := os.ReadFile(zoneFile(machineFolder, machineID))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// RecordZone remembers the machine's zone after it was restored into another one, so later commands
+// find the machine there. Stateless machines need ZONE to be updated instead.
+func (o *Options) RecordZone() error {
+	if o.Stateless || o.MachineFolder == "" {
+		return nil
+	}
+
+	path := zoneFile(o.MachineFolder, o.MachineID)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(o.Zone+"\n"), 0644)
+}
+
+// ForgetZone removes the recorded zone of a deleted machine
+func (o *Options) ForgetZone() error {
+	if o.Stateless || o.MachineFolder == "" {
+		return nil
+	}
+
+	err := os.Remove(zoneFile(o.MachineFolder, o.MachineID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// useRecordedZone switches to the zone the machine was restored into
+func (o *Options) useRecordedZone() {
+	if o.Stateless || o.MachineFolder == "" {
+		return
+	}
+
+	zone := recordedZone(o.MachineFolder, o.MachineID)
+	if zone != "" && zone != o.Zone {
+		log.Default.ErrorStreamOnly().Infof("Using zone %s that machine %s was restored into", zone, o.MachineID)
+		o.Zone = zone
+	}
+}
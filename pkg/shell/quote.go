@@ -0,0 +1,17 @@
+package shell
+
+import (
+	"regexp"
+	"strings"
+)
+
+var safeRegEx = regexp.MustCompile(`^[A-Za-z0-9_./:@%+=,-]+$`)
+
+// Quote quotes the value for a posix shell, values that don't need quoting are returned as they are
+func Quote(value string) string {
+	if safeRegEx.MatchString(value) {
+		return value
+	}
+
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,209 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Forward is a port forwarding between the local machine and the instance
+type Forward struct {
+	// Remote forwards a port of the instance to the local machine instead of the other way round
+	Remote bool
+	// Dynamic runs a socks5 proxy on ListenAddr that connects to the requested addresses from the instance
+	Dynamic bool
+	// ListenAddr is where connections are accepted, locally or on the instance for Remote forwards
+	ListenAddr string
+	// TargetAddr is dialed for every connection, from the instance or locally for Remote forwards.
+	// It is empty for Dynamic forwards.
+	TargetAddr string
+}
+
+func (f Forward) String() string {
+	if f.Dynamic {
+		return fmt.Sprintf("socks proxy %s -> instance", f.ListenAddr)
+	} else if f.Remote {
+		return fmt.Sprintf("instance %s -> local %s", f.ListenAddr, f.TargetAddr)
+	}
+
+	return fmt.Sprintf("local %s -> instance %s", f.ListenAddr, f.TargetAddr)
+}
+
+// ParseForward parses a forwarding in the format of ssh -L and -R, [bind_address:]port:host:hostport,
+// with an optional L: (the default) or R: prefix for remote forwards. D:[bind_address:]port is a
+// dynamic forward like ssh -D.
+func ParseForward(spec string) (Forward, error) {
+	forward := Forward{}
+	rest := spec
+	if strings.HasPrefix(rest, "L:") {
+		rest = strings.TrimPrefix(rest, "L:")
+	} else if strings.HasPrefix(rest, "R:") {
+		forward.Remote = true
+		rest = strings.TrimPrefix(rest, "R:")
+	} else if strings.HasPrefix(rest, "D:") {
+		forward.Dynamic = true
+		rest = strings.TrimPrefix(rest, "D:")
+	}
+
+	parts := splitForward(rest)
+	bindAddress := "127.0.0.1"
+	if forward.Dynamic {
+		if len(parts) == 2 {
+			bindAddress = parts[0]
+			parts = parts[1:]
+		} else if len(parts) != 1 {
+			return Forward{}, fmt.Errorf("invalid dynamic forward %s, expected D:[bind_address:]port", spec)
+		}
+
+		number, err := strconv.Atoi(parts[0])
+		if err != nil || number < 1 || number > 65535 {
+			return Forward{}, fmt.Errorf("invalid port %s in forward %s", parts[0], spec)
+		}
+
+		forward.ListenAddr = net.JoinHostPort(bindAddress, parts[0])
+		return forward, nil
+	}
+
+	switch len(parts) {
+	case 3:
+	case 4:
+		bindAddress = parts[0]
+		parts = parts[1:]
+	default:
+		return Forward{}, fmt.Errorf("invalid forward %s, expected [L:|R:][bind_address:]port:host:hostport", spec)
+	}
+
+	for _, port := range []string{parts[0], parts[2]} {
+		number, err := strconv.Atoi(port)
+		if err != nil || number < 1 || number > 65535 {
+			return Forward{}, fmt.Errorf("invalid port %s in forward %s", port, spec)
+		}
+	}
+	if parts[1] == "" {
+		return Forward{}, fmt.Errorf("missing host in forward %s", spec)
+	}
+
+	forward.ListenAddr = net.JoinHostPort(bindAddress, parts[0])
+	forward.TargetAddr = net.JoinHostPort(parts[1], parts[2])
+	return forward, nil
+}
+
+// splitForward splits at colons outside of brackets, so ipv6 addresses can be given as [::1]
+func splitForward(spec string) []string {
+	parts := []string{}
+	depth, start := 0, 0
+	for i, c := range spec {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				parts = append(parts, strings.Trim(spec[start:i], "[]"))
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, strings.Trim(spec[start:], "[]"))
+}
+
+// StartForwards listens for all forwards and tunnels their connections through the client until
+// the returned function is called. Failed connections are passed to logf, they don't stop the
+// forwarding.
+func StartForwards(client *ssh.Client, forwards []Forward, logf func(format string, args ...interface{})) (func(), error) {
+	listeners := []net.Listener{}
+	stop := func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}
+
+	for _, forward := range forwards {
+		var (
+			listener net.Listener
+			dial     func(network, addr string) (net.Conn, error)
+			err      error
+		)
+		if forward.Remote {
+			listener, err = client.Listen("tcp", forward.ListenAddr)
+			dial = net.Dial
+		} else {
+			listener, err = net.Listen("tcp", forward.ListenAddr)
+			dial = client.Dial
+		}
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("listen for forward %s: %w", forward, err)
+		}
+		listeners = append(listeners, listener)
+
+		go acceptForward(listener, forward, dial, logf)
+	}
+
+	return stop, nil
+}
+
+func acceptForward(listener net.Listener, forward Forward, dial func(network, addr string) (net.Conn, error), logf func(format string, args ...interface{})) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			var err error
+			targetAddr := forward.TargetAddr
+			if forward.Dynamic {
+				targetAddr, err = readSocksRequest(conn)
+				if err != nil {
+					logf("Error reading socks request: %v", err)
+					return
+				}
+			}
+
+			target, err := dial("tcp", targetAddr)
+			if forward.Dynamic {
+				reply := byte(socksSucceeded)
+				if err != nil {
+					reply = socksGeneralFailure
+				}
+				writeSocksReply(conn, reply)
+			}
+			if err != nil {
+				logf("Error forwarding %s to %s: %v", forward, targetAddr, err)
+				return
+			}
+			defer target.Close()
+
+			pipe(conn, target)
+		}()
+	}
+}
+
+// pipe copies between both connections until one of them is closed
+func pipe(a, b net.Conn) {
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	copyConn := func(dst, src net.Conn) {
+		defer wg.Done()
+		_, _ = io.Copy(dst, src)
+		// let the other side finish sending instead of dropping its response
+		if closer, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = closer.CloseWrite()
+		} else {
+			_ = dst.Close()
+		}
+	}
+	go copyConn(a, b)
+	go copyConn(b, a)
+	wg.Wait()
+}
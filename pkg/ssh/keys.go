@@ -0,0 +1,412 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	DevPodSSHPrivateKeyFile = "id_devpod_rsa"
+	DevPodSSHPublicKeyFile  = "id_devpod_rsa.pub"
+	DevPodSSHKeyTypeFile    = "id_devpod_rsa.type"
+)
+
+var keyLock sync.Mutex
+
+// KeyType is the algorithm of generated keys
+type KeyType string
+
+const (
+	KeyTypeRSA2048   KeyType = "rsa-2048"
+	KeyTypeRSA4096   KeyType = "rsa-4096"
+	KeyTypeECDSAP256 KeyType = "ecdsa-p256"
+	KeyTypeED25519   KeyType = "ed25519"
+)
+
+// KeyTypes are all supported key types, the first one is the default
+var KeyTypes = []KeyType{KeyTypeRSA2048, KeyTypeRSA4096, KeyTypeECDSAP256, KeyTypeED25519}
+
+// GenerateKeyPair generates a new private key of the given type in pem format and the matching public
+// key in authorized_keys format
+func GenerateKeyPair(keyType KeyType) ([]byte, []byte, error) {
+	var privateKeyRaw crypto.Signer
+	var block *pem.Block
+	switch keyType {
+	case KeyTypeRSA2048, KeyTypeRSA4096, "":
+		bits := 2048
+		if keyType == KeyTypeRSA4096 {
+			bits = 4096
+		}
+
+		rsaKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, nil, errors.Errorf("generate private key: %v", err)
+		}
+
+		privateKeyRaw = rsaKey
+		block = &pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+		}
+	case KeyTypeECDSAP256:
+		ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, errors.Errorf("generate private key: %v", err)
+		}
+
+		der, err := x509.MarshalECPrivateKey(ecdsaKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		privateKeyRaw = ecdsaKey
+		block = &pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: der,
+		}
+	case KeyTypeED25519:
+		_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, errors.Errorf("generate private key: %v", err)
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		privateKeyRaw = ed25519Key
+		block = &pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: der,
+		}
+	default:
+		return nil, nil, errors.Errorf("unsupported key type %s", keyType)
+	}
+
+	publicKey, err := ssh.NewPublicKey(privateKeyRaw.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(block), ssh.MarshalAuthorizedKey(publicKey), nil
+}
+
+// GetPrivateKeyRawBase returns the private key in the given folder and generates a key pair of the
+// given type if there is none yet
+func GetPrivateKeyRawBase(dir string, keyType KeyType) ([]byte, error) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	unlock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = prepareDir(dir, keyType)
+	if err != nil {
+		return nil, err
+	}
+
+	// read private key
+	out, err := os.ReadFile(filepath.Join(dir, DevPodSSHPrivateKeyFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "read private ssh key")
+	}
+
+	return out, nil
+}
+
+// GetPublicKeyBase returns the base64 encoded public key in the given folder and generates a key
+// pair of the given type if there is none yet
+func GetPublicKeyBase(dir string, keyType KeyType) (string, error) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	unlock, err := lockDir(dir)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	err = prepareDir(dir, keyType)
+	if err != nil {
+		return "", err
+	}
+
+	// read public key
+	out, err := os.ReadFile(filepath.Join(dir, DevPodSSHPublicKeyFile))
+	if err != nil {
+		return "", errors.Wrap(err, "read public ssh key")
+	}
+
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// ReadPrivateKey reads a private key from the given path, a leading ~ is expanded to the home directory
+func ReadPrivateKey(path string) ([]byte, error) {
+	path, err := expandPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read private ssh key")
+	}
+
+	_, err = ssh.ParseRawPrivateKey(out)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse private ssh key %s", path)
+	}
+
+	return out, nil
+}
+
+// ReadPublicKey returns the public key in authorized_keys format. The value is either the key itself
+// or a path to a file containing it.
+func ReadPublicKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	publicKey := []byte(value)
+	if !strings.HasPrefix(value, "ssh-") && !strings.HasPrefix(value, "ecdsa-") && !strings.HasPrefix(value, "sk-") {
+		path, err := expandPath(value)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey, err = os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "read public ssh key")
+		}
+	}
+
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse public ssh key")
+	}
+
+	return ssh.MarshalAuthorizedKey(parsed), nil
+}
+
+// ParsePrivateKey returns the private key given as pem or base64 encoded pem in pem format
+func ParsePrivateKey(value []byte) ([]byte, error) {
+	privateKey := bytes.TrimSpace(value)
+	if !bytes.HasPrefix(privateKey, []byte("-----BEGIN")) {
+		decoded, err := base64.StdEncoding.DecodeString(string(privateKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "decode private ssh key")
+		}
+
+		privateKey = bytes.TrimSpace(decoded)
+	}
+
+	_, err := ssh.ParseRawPrivateKey(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private ssh key")
+	}
+
+	return append(privateKey, '\n'), nil
+}
+
+// PublicKeyFromPrivateKey derives the public key in authorized_keys format from the given private key
+func PublicKeyFromPrivateKey(privateKey []byte) ([]byte, error) {
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse private key")
+	}
+
+	return ssh.MarshalAuthorizedKey(signer.PublicKey()), nil
+}
+
+// WriteKeyPair replaces the key pair in the given folder
+func WriteKeyPair(dir string, privateKey, publicKey []byte, keyType KeyType) error {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	unlock, err := lockDir(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeKeyPair(dir, privateKey, publicKey, keyType)
+}
+
+// MachineKeyDir returns the folder that holds the key pair of the given machine, so that every machine
+// has its own key. Key pairs that were stored directly in the machine folder or in a folder named
+// after the instance are moved there.
+func MachineKeyDir(machineFolder, machineID, instanceName string) (string, error) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	dir := filepath.Join(machineFolder, "keys", machineID)
+	if instanceName != "" && instanceName != machineID {
+		err := moveKeyDir(machineFolder, filepath.Join(machineFolder, "keys", instanceName), dir)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	_, err := os.Stat(filepath.Join(machineFolder, DevPodSSHPrivateKeyFile))
+	if err != nil {
+		return dir, nil
+	}
+
+	unlock, err := lockDir(machineFolder)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	// move the legacy key pair unless the machine already has its own
+	_, err = os.Stat(filepath.Join(dir, DevPodSSHPrivateKeyFile))
+	for _, file := range []string{DevPodSSHPublicKeyFile, DevPodSSHKeyTypeFile, DevPodSSHPrivateKeyFile} {
+		legacyPath := filepath.Join(machineFolder, file)
+		if os.IsNotExist(err) {
+			renameErr := os.Rename(legacyPath, filepath.Join(dir, file))
+			if renameErr != nil && !os.IsNotExist(renameErr) {
+				return "", errors.Wrapf(renameErr, "move %s", file)
+			}
+		} else {
+			_ = os.Remove(legacyPath)
+		}
+	}
+
+	return dir, nil
+}
+
+// moveKeyDir renames the key folder from to the key folder to, unless to exists already
+func moveKeyDir(machineFolder, from, to string) error {
+	_, err := os.Stat(from)
+	if err != nil {
+		return nil
+	}
+
+	unlock, err := lockDir(machineFolder)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, err = os.Stat(to)
+	if !os.IsNotExist(err) {
+		return nil
+	}
+
+	err = os.Rename(from, to)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "move %s", from)
+	}
+
+	return nil
+}
+
+// GetKeyType returns the type of the key pair in the given folder, keys that were generated before
+// the type was recorded are rsa-2048
+func GetKeyType(dir string) (KeyType, error) {
+	out, err := os.ReadFile(filepath.Join(dir, DevPodSSHKeyTypeFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return KeyTypeRSA2048, nil
+		}
+
+		return "", err
+	}
+
+	return KeyType(strings.TrimSpace(string(out))), nil
+}
+
+// KeyAge returns how long ago the key pair in the given folder was written
+func KeyAge(dir string) (time.Duration, error) {
+	stat, err := os.Stat(filepath.Join(dir, DevPodSSHPrivateKeyFile))
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Since(stat.ModTime()), nil
+}
+
+func expandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// prepareDir makes sure the folder contains a key pair, the caller needs to hold the folder's lock.
+// An existing key pair is kept even if it has a different type.
+func prepareDir(dir string, keyType KeyType) error {
+	// check if key pair exists
+	privateKey, err := os.ReadFile(filepath.Join(dir, DevPodSSHPrivateKeyFile))
+	if err == nil {
+		_, err = os.Stat(filepath.Join(dir, DevPodSSHPublicKeyFile))
+		if err == nil {
+			return nil
+		}
+
+		// restore a missing public key instead of replacing the private key
+		publicKey, err := PublicKeyFromPrivateKey(privateKey)
+		if err != nil {
+			return err
+		}
+
+		return writeFileAtomic(filepath.Join(dir, DevPodSSHPublicKeyFile), publicKey, 0644)
+	}
+
+	privateKey, publicKey, err := GenerateKeyPair(keyType)
+	if err != nil {
+		return errors.Wrap(err, "generate key pair")
+	}
+
+	return writeKeyPair(dir, privateKey, publicKey, keyType)
+}
+
+func writeKeyPair(dir string, privateKey, publicKey []byte, keyType KeyType) error {
+	err := writeFileAtomic(filepath.Join(dir, DevPodSSHPublicKeyFile), publicKey, 0644)
+	if err != nil {
+		return errors.Wrap(err, "write public ssh key")
+	}
+
+	err = writeFileAtomic(filepath.Join(dir, DevPodSSHPrivateKeyFile), privateKey, 0600)
+	if err != nil {
+		return errors.Wrap(err, "write private ssh key")
+	}
+
+	if keyType == "" {
+		keyType = KeyTypeRSA2048
+	}
+	err = writeFileAtomic(filepath.Join(dir, DevPodSSHKeyTypeFile), []byte(keyType), 0644)
+	if err != nil {
+		return errors.Wrap(err, "write ssh key type")
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/lock"
+)
+
+const (
+	lockFile    = ".devpod-ssh.lock"
+	lockTimeout = time.Minute
+)
+
+// lockDir acquires an exclusive lock on the key folder that is shared with other provider processes
+// and returns a function that releases it
+func lockDir(dir string) (func(), error) {
+	return lock.File(filepath.Join(dir, lockFile), lockTimeout)
+}
+
+// writeFileAtomic writes the file to a temporary file in the same folder first and renames it, so
+// other processes never read a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(f.Name(), perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
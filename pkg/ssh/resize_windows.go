@@ -0,0 +1,12 @@
+//go:build windows
+
+package ssh
+
+import (
+	"golang.org/x/crypto/ssh"
+)
+
+// watchResize is a no-op on windows, which has no signal for terminal size changes
+func watchResize(fd int, sess *ssh.Session) func() {
+	return func() {}
+}
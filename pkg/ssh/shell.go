@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
+)
+
+// Shell opens an interactive login shell or, if command isn't empty, runs the command attached to
+// the local terminal. If stdin is a terminal it is switched to raw mode and the session gets a pty of
+// the same size, so editors and job control work as usual.
+func Shell(ctx context.Context, client *ssh.Client, command string, forwardAgent bool) error {
+	sess, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	if forwardAgent {
+		err = agent.RequestAgentForwarding(sess)
+		if err != nil {
+			return errors.Wrap(err, "request agent forwarding")
+		}
+	}
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		err = sess.RequestPty(termType, height, width, ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		})
+		if err != nil {
+			return errors.Wrap(err, "request pty")
+		}
+
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return errors.Wrap(err, "set terminal to raw mode")
+		}
+		defer func() { _ = term.Restore(fd, state) }()
+
+		stopResize := watchResize(fd, sess)
+		defer stopResize()
+	}
+
+	sess.Stdin = os.Stdin
+	sess.Stdout = os.Stdout
+	sess.Stderr = os.Stderr
+	if command == "" {
+		err = sess.Shell()
+	} else {
+		err = sess.Start(command)
+	}
+	if err != nil {
+		return err
+	}
+
+	exit := make(chan struct{})
+	defer close(exit)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = sess.Close()
+		case <-exit:
+		}
+	}()
+
+	return sess.Wait()
+}
@@ -0,0 +1,107 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// minimal socks5 server side, supporting the CONNECT command without authentication (RFC 1928)
+const (
+	socksVersion         = 5
+	socksNoAuth          = 0
+	socksNoAcceptable    = 0xff
+	socksCmdConnect      = 1
+	socksAddrIPv4        = 1
+	socksAddrDomain      = 3
+	socksAddrIPv6        = 4
+	socksSucceeded       = 0
+	socksGeneralFailure  = 1
+	socksCmdUnsupported  = 7
+	socksAddrUnsupported = 8
+)
+
+// readSocksRequest negotiates a socks5 connection and returns the address the client wants to
+// connect to. Host names are returned unresolved, so they are resolved on the instance.
+func readSocksRequest(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return "", err
+	} else if header[0] != socksVersion {
+		return "", fmt.Errorf("unsupported socks version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	_, err = io.ReadFull(conn, methods)
+	if err != nil {
+		return "", err
+	}
+	if !containsByte(methods, socksNoAuth) {
+		_, _ = conn.Write([]byte{socksVersion, socksNoAcceptable})
+		return "", fmt.Errorf("socks client requires authentication")
+	}
+	_, err = conn.Write([]byte{socksVersion, socksNoAuth})
+	if err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	_, err = io.ReadFull(conn, request)
+	if err != nil {
+		return "", err
+	} else if request[1] != socksCmdConnect {
+		writeSocksReply(conn, socksCmdUnsupported)
+		return "", fmt.Errorf("unsupported socks command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		ip := make([]byte, net.IPv4len)
+		if request[3] == socksAddrIPv6 {
+			ip = make([]byte, net.IPv6len)
+		}
+		_, err = io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case socksAddrDomain:
+		length := make([]byte, 1)
+		_, err = io.ReadFull(conn, length)
+		if err == nil {
+			domain := make([]byte, length[0])
+			_, err = io.ReadFull(conn, domain)
+			host = string(domain)
+		}
+	default:
+		writeSocksReply(conn, socksAddrUnsupported)
+		return "", fmt.Errorf("unsupported socks address type %d", request[3])
+	}
+	if err != nil {
+		return "", err
+	}
+
+	port := make([]byte, 2)
+	_, err = io.ReadFull(conn, port)
+	if err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// writeSocksReply answers a connect request, the bound address is left empty as clients don't need it
+func writeSocksReply(conn net.Conn, reply byte) {
+	_, _ = conn.Write([]byte{socksVersion, reply, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+}
+
+func containsByte(values []byte, value byte) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,216 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// dialTimeout bounds connecting and the ssh handshake, so an unreachable or hanging host fails
+// instead of blocking the command
+const dialTimeout = 30 * time.Second
+
+// Bastion describes a jump host that is used to reach the instance
+type Bastion struct {
+	Host string
+	User string
+}
+
+// NewSSHClient connects to the given address, optionally via a bastion host. With useAgent the keys
+// of the local ssh agent are tried in addition to the given key.
+func NewSSHClient(user, addr string, keyBytes []byte, useAgent bool, bastion *Bastion) (*ssh.Client, error) {
+	sshConfig, closeAgent, err := ConfigFromKeyBytes(keyBytes, useAgent)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	if user != "" {
+		sshConfig.User = user
+	}
+
+	if bastion == nil || bastion.Host == "" {
+		client, err := dial(addr, sshConfig)
+		if err != nil {
+			return nil, fmt.Errorf("dial to %v failed: %w", addr, err)
+		}
+
+		return client, nil
+	}
+
+	return dialViaBastion(addr, sshConfig, bastion, keyBytes, useAgent)
+}
+
+func dialViaBastion(addr string, sshConfig *ssh.ClientConfig, bastion *Bastion, keyBytes []byte, useAgent bool) (*ssh.Client, error) {
+	bastionConfig, closeAgent, err := ConfigFromKeyBytes(keyBytes, useAgent)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+	bastionConfig.User = bastion.User
+
+	bastionAddr := bastion.Host
+	if _, _, err := net.SplitHostPort(bastionAddr); err != nil {
+		bastionAddr = net.JoinHostPort(bastionAddr, "22")
+	}
+
+	bastionClient, err := dial(bastionAddr, bastionConfig)
+	if err != nil {
+		return nil, fmt.Errorf("dial to bastion %v failed: %w", bastionAddr, err)
+	}
+
+	conn, err := bastionClient.Dial("tcp", addr)
+	if err != nil {
+		_ = bastionClient.Close()
+		return nil, fmt.Errorf("dial to %v via bastion failed: %w", addr, err)
+	}
+
+	client, err := handshake(conn, addr, sshConfig)
+	if err != nil {
+		_ = bastionClient.Close()
+		return nil, fmt.Errorf("ssh handshake with %v via bastion failed: %w", addr, err)
+	}
+
+	go func() {
+		// tear down the bastion connection together with the instance connection
+		_ = client.Wait()
+		_ = bastionClient.Close()
+	}()
+
+	return client, nil
+}
+
+// dial connects to the address and runs the ssh handshake, both bounded by dialTimeout
+func dial(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return handshake(conn, addr, config)
+}
+
+// handshake runs the ssh handshake on the connection and closes it if that takes longer than dialTimeout
+func handshake(conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	timer := time.AfterFunc(dialTimeout, func() { _ = conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !timer.Stop() {
+		if err == nil {
+			_ = c.Close()
+		}
+		return nil, fmt.Errorf("ssh handshake timed out after %s", dialTimeout)
+	} else if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// ConfigFromKeyBytes creates a client config that authenticates with the given private key and,
+// with useAgent, the keys of the local ssh agent. Without a private key the agent is always used.
+// The returned func closes the agent connection and needs to be called once the handshake is done.
+func ConfigFromKeyBytes(keyBytes []byte, useAgent bool) (*ssh.ClientConfig, func(), error) {
+	clientConfig := &ssh.ClientConfig{
+		Auth:            []ssh.AuthMethod{},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         dialTimeout,
+	}
+
+	// key file authentication?
+	if len(keyBytes) > 0 {
+		signer, err := ssh.ParsePrivateKey(keyBytes)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "parse private key")
+		}
+
+		clientConfig.Auth = append(clientConfig.Auth, ssh.PublicKeys(signer))
+	}
+
+	// agent authentication?
+	closeAgent := func() {}
+	if useAgent || len(keyBytes) == 0 {
+		conn, err := dialAgent()
+		if err != nil {
+			return nil, nil, err
+		}
+		closeAgent = func() { _ = conn.Close() }
+
+		clientConfig.Auth = append(clientConfig.Auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	return clientConfig, closeAgent, nil
+}
+
+// dialAgent connects to the ssh agent listening on SSH_AUTH_SOCK
+func dialAgent() (net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("ssh agent requested but SSH_AUTH_SOCK is not set")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to ssh agent")
+	}
+
+	return conn, nil
+}
+
+// ForwardAgent makes the local ssh agent available to sessions of the client that request it
+// with RunForwardAgent
+func ForwardAgent(client *ssh.Client) error {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return fmt.Errorf("agent forwarding requested but SSH_AUTH_SOCK is not set")
+	}
+
+	return agent.ForwardToRemote(client, socket)
+}
+
+func Run(ctx context.Context, client *ssh.Client, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	return run(ctx, client, command, stdin, stdout, stderr, false)
+}
+
+// RunForwardAgent runs the command with agent forwarding, ForwardAgent needs to be called on the client first
+func RunForwardAgent(ctx context.Context, client *ssh.Client, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	return run(ctx, client, command, stdin, stdout, stderr, true)
+}
+
+func run(ctx context.Context, client *ssh.Client, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer, forwardAgent bool) error {
+	sess, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	if forwardAgent {
+		err = agent.RequestAgentForwarding(sess)
+		if err != nil {
+			return errors.Wrap(err, "request agent forwarding")
+		}
+	}
+
+	exit := make(chan struct{})
+	defer close(exit)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = sess.Signal(ssh.SIGINT)
+			_ = sess.Close()
+		case <-exit:
+		}
+	}()
+
+	sess.Stdin = stdin
+	sess.Stdout = stdout
+	sess.Stderr = stderr
+	return sess.Run(command)
+}
@@ -0,0 +1,76 @@
+package startup
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+)
+
+// templateOptions are the provider options templates can read, which leaves out secrets and the
+// rest of the environment like GCLOUD_JSON_AUTH
+var templateOptions = map[string]bool{}
+
+func init() {
+	for _, name := range []string{
+		"PROJECT", "ZONE", "NETWORK", "SUBNETWORK", "STACK_TYPE", "NIC_TYPE", "NETWORK_PERFORMANCE_TIER",
+		"NO_PUBLIC_IP", "CREATE_CLOUD_NAT", "ORG_POLICY_MODE", "API_ENDPOINT", "BASTION_HOST", "BASTION_USER",
+		"SSH_KEY_ROTATION_DAYS", "SSH_KEY_TYPE", "SSH_PRIVATE_KEY_PATH", "SSH_PUBLIC_KEY", "STATELESS",
+		"MACHINE_FOLDER_OVERRIDE", "PROJECT_OVERRIDE", "SSH_AGENT", "SSH_AGENT_FORWARDING", "NAME_TEMPLATE", "INSTANCE_HOSTNAME",
+		"ADDITIONAL_NETWORK_INTERFACES", "NETWORK_PROJECT", "TAG", "DISK_SIZE", "DISK_TYPE", "PROVISIONED_IOPS",
+		"PROVISIONED_THROUGHPUT", "DISK_IMAGE", "IMAGE_FAMILY", "LABELS", "TEMPLATING", "SERVICE_ACCOUNT",
+		"DOCKER_CREDENTIAL_HELPER", "DOCKER_REGISTRIES", "INSTALL_OPS_AGENT", "FILESTORE_SHARE", "FILESTORE_PATH",
+		"GCS_BUCKET_MOUNT", "WARM_POOL_SIZE", "MACHINE_TYPE", "ACCELERATOR_TYPE", "ACCELERATOR_COUNT", "GPU_IMAGE",
+		"SPOT", "SPOT_TERMINATION_ACTION", "SPOT_AUTO_RECOVER", "KEEP_DISK_ON_DELETE", "DATA_DISK_SIZE",
+		"LOCAL_SSD_COUNT", "RELOCATE_AGENT_DIR", "AUTOMATIC_RESTART", "ON_HOST_MAINTENANCE", "PLACEMENT_POLICY",
+		"SHIELDED_VM", "BLOCK_PROJECT_SSH_KEYS", "INACTIVITY_TIMEOUT", "INJECT_GIT_CREDENTIALS",
+		"INJECT_DOCKER_CREDENTIALS", "READY_TIMEOUT", "STOP_GRACE_PERIOD", "PRE_STOP_COMMAND", "METRICS_FILE",
+		"AGENT_PATH",
+	} {
+		templateOptions[name] = true
+	}
+}
+
+// option returns the value of the given provider option
+func option(name string) (string, error) {
+	if !templateOptions[name] {
+		return "", fmt.Errorf("unknown option %s", name)
+	}
+
+	return os.Getenv(name), nil
+}
+
+// Render executes the given text as a go template with the machine's variables, e.g.
+// {{ .MachineID }} or {{ option "ZONE" }} for a provider option
+func Render(name, text string, o *options.Options) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"option": option,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %w", name, err)
+	}
+
+	workspaceID := os.Getenv("WORKSPACE_ID")
+	if workspaceID == "" {
+		workspaceID = o.MachineID
+	}
+
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, map[string]interface{}{
+		"MachineID":   o.MachineID,
+		"WorkspaceID": workspaceID,
+		"User":        options.CurrentUser(),
+		"Project":     o.Project,
+		"Zone":        o.Zone,
+		"Region":      o.Region(),
+		"MachineType": o.MachineType,
+		"Labels":      o.Labels,
+	})
+	if err != nil {
+		return "", fmt.Errorf("render %s template: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
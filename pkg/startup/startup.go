@@ -0,0 +1,368 @@
+package startup
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/options"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/shell"
+	"github.com/loft-sh/devpod-provider-gcloud/pkg/version"
+)
+
+// DataDiskDeviceName is the device name of the data disk inside the instance
+const DataDiskDeviceName = "devpod-data"
+
+// DoneMarker is written to the serial console once the startup script finished provisioning
+const DoneMarker = "devpod-provisioning-done"
+
+// The startup script reports its progress in the guest attribute devpod/phase
+const (
+	PhaseKey = "devpod/phase"
+
+	PhaseStarted         = "started"
+	PhaseDockerInstalled = "docker-installed"
+	PhaseAgentReady      = "agent-ready"
+	PhaseDone            = "done"
+	PhaseErrorPrefix     = "error:"
+)
+
+var scriptTemplate = template.Must(template.New("startup").Funcs(template.FuncMap{"quote": shell.Quote}).Parse(`#!/bin/bash
+set -e
+
+report_phase() {
+  curl -s -X PUT --data "$1" -H "Metadata-Flavor: Google" \
+    "http://metadata.google.internal/computeMetadata/v1/instance/guest-attributes/{{ .PhaseKey }}" >/dev/null || true
+}
+trap 'report_phase "{{ .PhaseErrorPrefix }}command \"$BASH_COMMAND\" failed with exit code $?"' ERR
+report_phase {{ .PhaseStarted }}
+
+{{ if .GoogleAPIsVIP }}
+# route registry traffic through the private google apis vip
+if ! grep -q "devpod-google-apis" /etc/hosts; then
+  cat >> /etc/hosts <<'DEVPOD_HOSTS_EOF'
+# devpod-google-apis
+{{- range .GoogleAPIsHosts }}
+{{ $.GoogleAPIsVIP }} {{ . }}
+{{- end }}
+DEVPOD_HOSTS_EOF
+fi
+{{ end }}
+# install docker if the image doesn't ship it already
+if ! command -v docker >/dev/null 2>&1; then
+  curl -fsSL https://get.docker.com | sh
+fi
+{{- if .DataDevices }}
+# move docker's data-root onto the data disk or local ssds, the script runs on every boot so
+# docker is restarted after the disk got mounted
+DATA_MOUNT=/mnt/disks/devpod-data
+if ! mountpoint -q "$DATA_MOUNT"; then
+  DATA_DEVICE={{ index .DataDevices 0 }}
+{{- if gt (len .DataDevices) 1 }}
+  if ! command -v mdadm >/dev/null 2>&1 && command -v apt-get >/dev/null 2>&1; then
+    apt-get update && apt-get install -y mdadm
+  fi
+  if command -v mdadm >/dev/null 2>&1; then
+    mdadm --assemble --scan || true
+    if [ ! -e /dev/md/devpod-data ]; then
+      mdadm --create /dev/md/devpod-data --name=devpod-data --level=0 --raid-devices={{ len .DataDevices }} {{ range .DataDevices }}{{ . }} {{ end }}--run
+    fi
+    DATA_DEVICE=/dev/md/devpod-data
+  else
+    echo "mdadm isn't available, only using the first local ssd"
+  fi
+{{- end }}
+  if ! blkid "$DATA_DEVICE" >/dev/null 2>&1; then
+    mkfs.ext4 -F -m 0 -E lazy_itable_init=0,lazy_journal_init=0,discard "$DATA_DEVICE"
+  fi
+  mkdir -p "$DATA_MOUNT"
+  mount -o discard,defaults "$DATA_DEVICE" "$DATA_MOUNT"
+fi
+# grow the filesystem after the data disk was resized
+resize2fs "$(findmnt -n -o SOURCE "$DATA_MOUNT")" >/dev/null 2>&1 || true
+DOCKER_ROOT="$DATA_MOUNT/docker"
+if [ ! -d "$DOCKER_ROOT" ]; then
+  systemctl stop docker docker.socket || true
+  # keep images that were baked into the boot disk
+  if [ -d /var/lib/docker ]; then
+    cp -a /var/lib/docker "$DOCKER_ROOT"
+  else
+    mkdir -p "$DOCKER_ROOT"
+  fi
+fi
+mkdir -p /etc/docker
+if [ ! -s /etc/docker/daemon.json ] || [ "$(tr -d ' \n' < /etc/docker/daemon.json)" = "{}" ]; then
+  echo "{\"data-root\": \"$DOCKER_ROOT\"}" > /etc/docker/daemon.json
+elif ! grep -q '"data-root"' /etc/docker/daemon.json; then
+  sed -i "0,/{/s##{\"data-root\": \"$DOCKER_ROOT\", #" /etc/docker/daemon.json
+fi
+systemctl restart docker
+{{- if .RelocateAgentDir }}
+mkdir -p "$DATA_MOUNT/devpod-agent" {{ quote .AgentPath }}
+if ! mountpoint -q {{ quote .AgentPath }}; then
+  cp -an {{ quote .AgentPath }}/. "$DATA_MOUNT/devpod-agent/" || true
+  mount --bind "$DATA_MOUNT/devpod-agent" {{ quote .AgentPath }}
+fi
+{{- end }}
+{{- end }}
+report_phase {{ .PhaseDockerInstalled }}
+{{- if .DockerRegistries }}
+# let docker pull from container and artifact registry with the instance's service account
+if command -v docker-credential-gcr >/dev/null 2>&1; then
+  HOME=/root docker-credential-gcr configure-docker --registries={{ .DockerRegistries }}
+  if [ -d /home/devpod ]; then
+    HOME=/home/devpod docker-credential-gcr configure-docker --registries={{ .DockerRegistries }}
+  fi
+elif command -v gcloud >/dev/null 2>&1; then
+  HOME=/root gcloud auth configure-docker {{ .DockerRegistries }} --quiet
+  if [ -d /home/devpod ]; then
+    HOME=/home/devpod gcloud auth configure-docker {{ .DockerRegistries }} --quiet
+  fi
+else
+  report_phase "{{ .PhaseErrorPrefix }}DOCKER_CREDENTIAL_HELPER needs docker-credential-gcr or gcloud on the image"
+  echo "DOCKER_CREDENTIAL_HELPER needs docker-credential-gcr or gcloud on the image" >&2
+  exit 1
+fi
+for dir in /home/devpod/.docker /home/devpod/.config/gcloud; do
+  if [ -d "$dir" ]; then
+    chown -R devpod: "$dir"
+  fi
+done
+{{- end }}
+{{ if .InstallNvidiaToolkit }}
+# make gpus available to containers with docker run --gpus
+if grep -q "^ID=cos" /etc/os-release; then
+  echo "skipping nvidia container toolkit installation, it isn't supported on container-optimized os"
+else
+  echo "waiting for the nvidia driver"
+  for i in $(seq 1 60); do
+    if nvidia-smi >/dev/null 2>&1; then
+      break
+    fi
+    sleep 10
+  done
+  if ! nvidia-smi; then
+    echo "the nvidia driver isn't ready, skipping the nvidia container toolkit installation"
+  else
+    if ! command -v nvidia-ctk >/dev/null 2>&1; then
+      curl -fsSL https://nvidia.github.io/libnvidia-container/gpgkey | gpg --dearmor --yes -o /usr/share/keyrings/nvidia-container-toolkit-keyring.gpg
+      curl -fsSL https://nvidia.github.io/libnvidia-container/stable/deb/nvidia-container-toolkit.list | \
+        sed 's#deb https://#deb [signed-by=/usr/share/keyrings/nvidia-container-toolkit-keyring.gpg] https://#g' > /etc/apt/sources.list.d/nvidia-container-toolkit.list
+      apt-get update
+      apt-get install -y nvidia-container-toolkit
+    fi
+    nvidia-ctk runtime configure --runtime=docker
+    systemctl restart docker
+  fi
+fi
+{{ end }}
+{{- if .FilestoreShare }}
+# mount the filestore share and keep it mounted across reboots
+if ! command -v mount.nfs >/dev/null 2>&1; then
+  if command -v apt-get >/dev/null 2>&1; then
+    (apt-get update && apt-get install -y nfs-common) || echo "installing nfs-common failed"
+  elif command -v dnf >/dev/null 2>&1; then
+    dnf install -y nfs-utils || echo "installing nfs-utils failed"
+  elif command -v yum >/dev/null 2>&1; then
+    yum install -y nfs-utils || echo "installing nfs-utils failed"
+  fi
+fi
+if ! command -v mount.nfs >/dev/null 2>&1; then
+  echo "skipping the filestore share, mount.nfs isn't available"
+else
+  mkdir -p {{ .FilestorePath }}
+  if ! grep -q "^{{ .FilestoreShare }} {{ .FilestorePath }} " /etc/fstab; then
+    echo "{{ .FilestoreShare }} {{ .FilestorePath }} nfs defaults,_netdev,nofail 0 0" >> /etc/fstab
+  fi
+  if ! mountpoint -q {{ .FilestorePath }}; then
+    mount {{ .FilestorePath }} || echo "mounting the filestore share failed"
+  fi
+fi
+{{ end }}
+{{- if .GCSBucket }}
+# mount the bucket with gcsfuse using the instance's service account
+if grep -q "^ID=cos" /etc/os-release; then
+  echo "skipping gcsfuse installation, it isn't supported on container-optimized os"
+else
+  if ! command -v gcsfuse >/dev/null 2>&1; then
+    if command -v apt-get >/dev/null 2>&1; then
+      curl -fsSL https://packages.cloud.google.com/apt/doc/apt-key.gpg | gpg --dearmor --yes -o /usr/share/keyrings/cloud.google.gpg
+      echo "deb [signed-by=/usr/share/keyrings/cloud.google.gpg] https://packages.cloud.google.com/apt gcsfuse-$(. /etc/os-release && echo "$VERSION_CODENAME") main" > /etc/apt/sources.list.d/gcsfuse.list
+      apt-get update
+      apt-get install -y gcsfuse
+    else
+      cat > /etc/yum.repos.d/gcsfuse.repo <<'DEVPOD_GCSFUSE_EOF'
+[gcsfuse]
+name=gcsfuse (packages.cloud.google.com)
+baseurl=https://packages.cloud.google.com/yum/repos/gcsfuse-el7-x86_64
+enabled=1
+gpgcheck=1
+repo_gpgcheck=0
+gpgkey=https://packages.cloud.google.com/yum/doc/yum-key.gpg https://packages.cloud.google.com/yum/doc/rpm-package-key.gpg
+DEVPOD_GCSFUSE_EOF
+      yum install -y gcsfuse
+    fi
+  fi
+  mkdir -p {{ .GCSMountPath }}
+  if ! grep -q "^{{ .GCSBucket }} {{ .GCSMountPath }} " /etc/fstab; then
+    echo "{{ .GCSBucket }} {{ .GCSMountPath }} gcsfuse rw,_netdev,nofail,allow_other,implicit_dirs,file_mode=666,dir_mode=777{{ if .GCSBucketDir }},only_dir={{ .GCSBucketDir }}{{ end }} 0 0" >> /etc/fstab
+  fi
+  if ! mountpoint -q {{ .GCSMountPath }}; then
+    mount {{ .GCSMountPath }}
+  fi
+fi
+{{ end }}
+# prefetch the devpod agent
+AGENT_PATH={{ quote .AgentPath }}
+if [ ! -x "$AGENT_PATH/devpod" ]; then
+  ARCH=amd64
+  if [ "$(uname -m)" = "aarch64" ]; then
+    ARCH=arm64
+  fi
+  mkdir -p "$AGENT_PATH"
+{{- if .AgentVersion }}
+  curl -fsSL -o "$AGENT_PATH/devpod" "https://github.com/loft-sh/devpod/releases/download/{{ .AgentVersion }}/devpod-linux-$ARCH"
+{{- else }}
+  curl -fsSL -o "$AGENT_PATH/devpod" "https://github.com/loft-sh/devpod/releases/latest/download/devpod-linux-$ARCH"
+{{- end }}
+  chmod +x "$AGENT_PATH/devpod"
+fi
+report_phase {{ .PhaseAgentReady }}
+{{ if .InstallOpsAgent }}
+# install the google cloud ops agent for metrics and syslog
+if grep -q "^ID=cos" /etc/os-release; then
+  echo "skipping ops agent installation, it isn't supported on container-optimized os"
+elif ! systemctl is-active --quiet google-cloud-ops-agent; then
+  curl -fsSL -o /tmp/add-google-cloud-ops-agent-repo.sh https://dl.google.com/cloudagents/add-google-cloud-ops-agent-repo.sh
+  bash /tmp/add-google-cloud-ops-agent-repo.sh --also-install
+  cat > /etc/google-cloud-ops-agent/config.yaml <<'DEVPOD_OPS_AGENT_EOF'
+logging:
+  service:
+    pipelines:
+      default_pipeline:
+        receivers: [syslog]
+metrics:
+  service:
+    pipelines:
+      default_pipeline:
+        receivers: [hostmetrics]
+DEVPOD_OPS_AGENT_EOF
+  systemctl restart google-cloud-ops-agent
+fi
+{{ end }}
+{{- if .StartupScript }}
+# run user provided provisioning
+cat > /tmp/devpod-user-startup.sh <<'DEVPOD_USER_STARTUP_EOF'
+{{ .StartupScript }}
+DEVPOD_USER_STARTUP_EOF
+bash /tmp/devpod-user-startup.sh
+{{ end }}
+report_phase {{ .PhaseDone }}
+echo {{ .DoneMarker }}
+`))
+
+// Script returns the startup script that provisions a devpod instance
+func Script(options *options.Options) (string, error) {
+	startupScript := options.StartupScript
+	if options.Templating && startupScript != "" {
+		var err error
+		startupScript, err = Render("STARTUP_SCRIPT", startupScript, options)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := scriptTemplate.Execute(buf, map[string]interface{}{
+		"AgentPath":            options.AgentPath,
+		"AgentVersion":         version.DevPodRelease(),
+		"StartupScript":        startupScript,
+		"DoneMarker":           DoneMarker,
+		"PhaseKey":             PhaseKey,
+		"PhaseStarted":         PhaseStarted,
+		"PhaseDockerInstalled": PhaseDockerInstalled,
+		"PhaseAgentReady":      PhaseAgentReady,
+		"PhaseDone":            PhaseDone,
+		"PhaseErrorPrefix":     PhaseErrorPrefix,
+		"GoogleAPIsVIP":        googleAPIsVIP(options.APIEndpoint),
+		"GoogleAPIsHosts":      googleAPIsHosts(options),
+		"InstallOpsAgent":      options.InstallOpsAgent,
+		"DataDevices":          dataDevices(options),
+		"RelocateAgentDir":     options.RelocateAgentDir,
+		"DockerRegistries":     dockerRegistries(options),
+		"InstallNvidiaToolkit": options.UseGPUImage(),
+		"FilestoreShare":       options.FilestoreShare,
+		"FilestorePath":        options.FilestorePath,
+		"GCSBucket":            options.GCSBucket,
+		"GCSBucketDir":         options.GCSBucketDir,
+		"GCSMountPath":         options.GCSMountPath,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// dockerRegistries returns the registries to configure the credential helper for
+func dockerRegistries(options *options.Options) string {
+	if !options.DockerCredentialHelper {
+		return ""
+	}
+
+	return strings.Join(options.DockerRegistries, ",")
+}
+
+// dataDevices returns the devices of the data disk or the local ssds
+func dataDevices(options *options.Options) []string {
+	if options.DataDiskSize > 0 {
+		return []string{"/dev/disk/by-id/google-" + DataDiskDeviceName}
+	}
+
+	devices := []string{}
+	for i := 0; i < options.LocalSSDCount; i++ {
+		devices = append(devices, fmt.Sprintf("/dev/disk/by-id/google-local-nvme-ssd-%d", i))
+	}
+
+	return devices
+}
+
+// googleAPIsHosts returns the registry and storage hosts that are routed through the googleapis vip.
+// /etc/hosts has no wildcards, so besides the configured registries the regional gcr.io hosts and the
+// multi-region and regional artifact registry hosts are listed explicitly.
+func googleAPIsHosts(options *options.Options) []string {
+	hosts := []string{
+		"gcr.io", "us.gcr.io", "eu.gcr.io", "asia.gcr.io",
+		options.Region() + "-docker.pkg.dev", "us-docker.pkg.dev", "europe-docker.pkg.dev", "asia-docker.pkg.dev",
+	}
+	for _, registry := range options.DockerRegistries {
+		if strings.HasSuffix(registry, ".gcr.io") || strings.HasSuffix(registry, ".pkg.dev") {
+			hosts = append(hosts, registry)
+		}
+	}
+	hosts = append(hosts, "storage.googleapis.com")
+
+	seen := map[string]bool{}
+	retHosts := []string{}
+	for _, host := range hosts {
+		if !seen[host] {
+			seen[host] = true
+			retHosts = append(retHosts, host)
+		}
+	}
+
+	return retHosts
+}
+
+// googleAPIsVIP returns the virtual ip of the private or restricted googleapis endpoint
+func googleAPIsVIP(endpoint string) string {
+	switch {
+	case strings.Contains(endpoint, "restricted.googleapis.com"):
+		return "199.36.153.4"
+	case strings.Contains(endpoint, "private.googleapis.com"):
+		return "199.36.153.8"
+	}
+
+	return ""
+}
@@ -0,0 +1,50 @@
+package version
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var releaseRegEx = regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?$`)
+
+var (
+	devPodOnce    sync.Once
+	devPodVersion string
+)
+
+// DevPod returns the version of the devpod binary that runs the provider or an empty string if it
+// is unknown
+func DevPod() string {
+	devPodOnce.Do(func() {
+		devPodPath := os.Getenv("DEVPOD")
+		if devPodPath == "" {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		out, err := exec.CommandContext(ctx, devPodPath, "version").Output()
+		if err == nil {
+			devPodVersion = strings.TrimSpace(string(out))
+		}
+	})
+
+	return devPodVersion
+}
+
+// DevPodRelease returns the release tag of the running devpod, e.g. v0.5.4, or an empty string for
+// unknown versions and development builds
+func DevPodRelease() string {
+	v := DevPod()
+	if !releaseRegEx.MatchString(v) || strings.TrimPrefix(v, "v") == "0.0.0" {
+		return ""
+	}
+
+	return "v" + strings.TrimPrefix(v, "v")
+}
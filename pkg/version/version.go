@@ -0,0 +1,4 @@
+package version
+
+// Version is the provider version, it is set during the release build
+var Version = "dev"